@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// largeBatches counts the large batch submissions currently being graded
+var largeBatches atomic.Int64
+
+// admitBatch decides whether a submission of caseCount test cases may start.
+// Submissions below LARGE_BATCH_CASES are always admitted; large ones are
+// limited to MAX_LARGE_BATCHES in flight so grading surges leave workers for
+// interactive executions. When admitted, release must be called once the
+// submission is done.
+func admitBatch(caseCount int) (release func(), ok bool) {
+	if config.MaxLargeBatches <= 0 || caseCount < config.LargeBatchCases {
+		return func() {}, true
+	}
+	if largeBatches.Add(1) > int64(config.MaxLargeBatches) {
+		largeBatches.Add(-1)
+		return nil, false
+	}
+	return func() { largeBatches.Add(-1) }, true
+}
+
+// sendServerBusy tells the client the runner is at capacity and when to retry
+func sendServerBusy(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(config.BusyRetryAfter.Seconds())))
+	http.Error(w, "Server is busy, please try again later", http.StatusTooManyRequests)
+}
+
+// sendBatchRejected tells the client to retry a large submission later
+func sendBatchRejected(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(config.LargeBatchRetryAfter.Seconds())))
+	http.Error(w, "Too many large submissions in flight, please try again later", http.StatusTooManyRequests)
+}
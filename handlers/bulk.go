@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"online-compiler/models"
+	"online-compiler/runner"
+	"sync"
+	"time"
+)
+
+// BulkExecuteRequest runs several independent programs in one round trip
+type BulkExecuteRequest struct {
+	Requests []models.ExecuteRequest `json:"requests"`
+}
+
+// BulkExecuteResponse holds one result per request, in request order
+type BulkExecuteResponse struct {
+	Status    string            `json:"status"`
+	Results   []ExecuteResponse `json:"results"`
+	Timestamp int64             `json:"timestamp"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// BulkExecuteHandler fans independent executions out to the worker pool,
+// at most BulkConcurrency at a time. A request that fails validation or
+// execution gets an error result without affecting the others.
+func BulkExecuteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), submitTimeout)
+	defer cancel()
+	setTimeoutHeader(w, submitTimeout)
+
+	var req BulkExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Requests) == 0 {
+		http.Error(w, "At least one request is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Requests) > config.MaxBulkRequests {
+		http.Error(w, fmt.Sprintf("Too many requests. Maximum allowed: %d", config.MaxBulkRequests), http.StatusBadRequest)
+		return
+	}
+	if !runner.ImageReady() {
+		http.Error(w, runner.ErrWarmingUp.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	results := make([]ExecuteResponse, len(req.Requests))
+	concurrency := config.BulkConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, item := range req.Requests {
+		wg.Add(1)
+		go func(i int, item models.ExecuteRequest) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = executeBulkItem(ctx, withIdentity(r, item))
+		}(i, item)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BulkExecuteResponse{
+		Status:    "success",
+		Results:   results,
+		Timestamp: time.Now().Unix(),
+		RequestID: requestID(r),
+	})
+}
+
+// executeBulkItem validates and runs one request of a bulk execution
+func executeBulkItem(ctx context.Context, req models.ExecuteRequest) ExecuteResponse {
+	response := ExecuteResponse{Status: "error", Timestamp: time.Now().Unix()}
+
+	if req.Language == "" || (req.Code == "" && len(req.Files) == 0 && req.Artifact == nil) {
+		response.Error = "Language and code (or files, or an artifact) are required"
+		return response
+	}
+	input, err := resolveInputRef(req.Input, req.InputRef)
+	if err != nil {
+		response.Error = err.Error()
+		return response
+	}
+	req.Input, req.InputRef = input, ""
+	if err := validateRequest(req); err != nil {
+		response.Error = err.Error()
+		return response
+	}
+	if err := runner.ValidateEnv(req.Env); err != nil {
+		response.Error = err.Error()
+		return response
+	}
+
+	startTime := time.Now()
+	result, err := executor.Execute(ctx, req)
+	response.Output = result.Output
+	response.CompileOutput = result.CompileOutput
+	response.RunStderr = result.Stderr
+	response.BinarySize = result.BinarySize
+	response.Profile = result.Profile
+	response.Cache = cacheStatus(req.NoCache)
+	response.Image = result.Image
+	response.StackSizeMB = runner.StackLimit(req.StackSizeMB)
+	response.Metrics = ExecutionMetrics{
+		ExecutionTime:      milliseconds(time.Since(startTime)),
+		MemoryPeakBytes:    result.Stats.MemoryPeakBytes,
+		MemoryCurrentBytes: result.Stats.MemoryCurrentBytes,
+	}
+	if err != nil {
+		response.Error = err.Error()
+		return response
+	}
+	response.Status = "success"
+	return response
+}
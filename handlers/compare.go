@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Comparison modes supported by SubmitHandler
+const (
+	CompareExact      = "exact"                  // Trimmed outputs must match exactly
+	CompareFirstDiff  = "first_diff"             // Like exact, but stops at and reports the first difference
+	CompareSimilar    = "similarity"             // Passes when enough lines match, see SimilarityThreshold
+	CompareRegex      = "regex"                  // The expected output is a regular expression the whole trimmed output must match
+	CompareWhitespace = "whitespace_insensitive" // Outputs must have the same whitespace-separated tokens
+	CompareFloat      = "float"                  // Like whitespace_insensitive, but numbers match within Epsilon
+)
+
+// defaultEpsilon is the float mode tolerance used when a request sets none
+const defaultEpsilon = 1e-6
+
+// maxCachedRegexes bounds the compiled expected-output patterns kept in memory
+const maxCachedRegexes = 1000
+
+// regexCache holds compiled regex-mode patterns so a pattern shared by many
+// cases or submissions is compiled once. When full, the least recently used
+// pattern is evicted.
+var regexCache = struct {
+	sync.Mutex
+	patterns map[string]*list.Element // Values are *cachedRegex
+	recent   *list.List               // Most recently used first
+}{patterns: make(map[string]*list.Element), recent: list.New()}
+
+// cachedRegex is a compiled pattern held in regexCache
+type cachedRegex struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// expectedRegex compiles a regex-mode expected output into a pattern that
+// must match the whole output
+func expectedRegex(expected string, ignoreCase bool) (*regexp.Regexp, error) {
+	pattern := `^(?:` + strings.TrimSpace(expected) + `)$`
+	if ignoreCase {
+		pattern = `(?i)` + pattern
+	}
+
+	regexCache.Lock()
+	defer regexCache.Unlock()
+	if elem, ok := regexCache.patterns[pattern]; ok {
+		regexCache.recent.MoveToFront(elem)
+		return elem.Value.(*cachedRegex).re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if regexCache.recent.Len() >= maxCachedRegexes {
+		oldest := regexCache.recent.Back()
+		regexCache.recent.Remove(oldest)
+		delete(regexCache.patterns, oldest.Value.(*cachedRegex).pattern)
+	}
+	regexCache.patterns[pattern] = regexCache.recent.PushFront(&cachedRegex{pattern: pattern, re: re})
+	return re, nil
+}
+
+// Mismatch locates the first difference between expected and actual output.
+// Line and column are 1-based; the column counts characters, not bytes.
+type Mismatch struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// CompareOptions controls how a test case's actual output is compared against the expected output
+type CompareOptions struct {
+	Mode       string `json:"comparison_mode,omitempty"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+	// Percentage of lines (0-100] that must match in similarity mode
+	SimilarityThreshold float64 `json:"similarity_threshold,omitempty"`
+	// Absolute or relative error allowed between numbers in float mode, 1e-6 if unset
+	Epsilon float64 `json:"epsilon,omitempty"`
+	// Transforms applied in order to both outputs before they are compared,
+	// e.g. ["trim", "sort_lines"]. In regex mode only the actual output is transformed.
+	Normalize []string `json:"normalize,omitempty"`
+}
+
+// validate checks that the comparison options are supported
+func (o CompareOptions) validate() error {
+	if err := validatePipeline(o.Normalize); err != nil {
+		return err
+	}
+	if o.Epsilon < 0 || math.IsNaN(o.Epsilon) || math.IsInf(o.Epsilon, 0) {
+		return fmt.Errorf("epsilon must be a finite number of at least 0")
+	}
+	if o.Epsilon != 0 && o.Mode != CompareFloat {
+		return fmt.Errorf("epsilon is only supported with comparison_mode %q", CompareFloat)
+	}
+	switch o.Mode {
+	case "", CompareExact, CompareFirstDiff, CompareRegex, CompareWhitespace, CompareFloat:
+	case CompareSimilar:
+		if o.SimilarityThreshold <= 0 || o.SimilarityThreshold > 100 {
+			return fmt.Errorf("similarity_threshold must be greater than 0 and at most 100")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported comparison mode: %s", o.Mode)
+	}
+	if o.SimilarityThreshold != 0 {
+		return fmt.Errorf("similarity_threshold is only supported with comparison_mode %q", CompareSimilar)
+	}
+	return nil
+}
+
+// comparison is the outcome of comparing one test case's output
+type comparison struct {
+	Passed     bool
+	Mismatch   *Mismatch // Set in first_diff mode when the outputs differ
+	Similarity *float64  // Set in similarity mode
+}
+
+// normalizeOutput applies the normalization pipeline, trimming and case folding selected by opts
+func normalizeOutput(output string, opts CompareOptions) string {
+	normalized := strings.TrimSpace(applyPipeline(output, opts.Normalize))
+
+	// Remove trailing newlines that might be added by different languages
+	normalized = strings.TrimRight(normalized, "\n\r")
+
+	if opts.IgnoreCase {
+		normalized = strings.ToLower(normalized)
+	}
+	return normalized
+}
+
+// compareOutputs compares actual against expected under opts, whose Mode
+// selects the comparison and whose other fields tune it. In first_diff mode it
+// also reports where the outputs first differ, and in similarity mode the
+// percentage of matching lines.
+func compareOutputs(expected, actual string, opts CompareOptions) comparison {
+	switch opts.Mode {
+	case CompareFirstDiff:
+		mismatch := firstMismatch(applyPipeline(expected, opts.Normalize), applyPipeline(actual, opts.Normalize), opts.IgnoreCase)
+		return comparison{Passed: mismatch == nil, Mismatch: mismatch}
+	case CompareSimilar:
+		similarity := lineSimilarity(normalizeOutput(expected, opts), normalizeOutput(actual, opts))
+		return comparison{Passed: similarity >= opts.SimilarityThreshold, Similarity: &similarity}
+	case CompareRegex:
+		// Patterns are checked when the request is validated
+		re, err := expectedRegex(expected, opts.IgnoreCase)
+		return comparison{Passed: err == nil && re.MatchString(normalizeOutput(actual, CompareOptions{Normalize: opts.Normalize}))}
+	case CompareWhitespace, CompareFloat:
+		return comparison{Passed: tokensMatch(normalizeOutput(expected, opts), normalizeOutput(actual, opts), opts)}
+	default:
+		return comparison{Passed: normalizeOutput(actual, opts) == normalizeOutput(expected, opts)}
+	}
+}
+
+// outputHash returns the sha256 of output normalized under opts, so outputs
+// that grade the same also hash the same. In float mode numbers are hashed as
+// printed, so outputs differing within epsilon hash differently.
+func outputHash(output string, opts CompareOptions) string {
+	normalized := normalizeOutput(output, opts)
+	if opts.Mode == CompareWhitespace || opts.Mode == CompareFloat {
+		normalized = strings.Join(strings.Fields(normalized), " ")
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokensMatch reports whether expected and actual have the same
+// whitespace-separated tokens, ignoring how they are spaced or broken into
+// lines. In float mode a numeric expected token also matches a number within
+// epsilon of it; any other token must match exactly.
+func tokensMatch(expected, actual string, opts CompareOptions) bool {
+	expectedTokens := strings.Fields(expected)
+	actualTokens := strings.Fields(actual)
+	if len(expectedTokens) != len(actualTokens) {
+		return false
+	}
+
+	epsilon := opts.Epsilon
+	if epsilon == 0 {
+		epsilon = defaultEpsilon
+	}
+	for i, e := range expectedTokens {
+		a := actualTokens[i]
+		if e == a {
+			continue
+		}
+		if opts.Mode != CompareFloat || !floatsMatch(e, a, epsilon) {
+			return false
+		}
+	}
+	return true
+}
+
+// floatsMatch reports whether both tokens are finite numbers whose absolute or
+// relative difference is at most epsilon
+func floatsMatch(expected, actual string, epsilon float64) bool {
+	e, ok := parseFinite(expected)
+	if !ok {
+		return false
+	}
+	a, ok := parseFinite(actual)
+	if !ok {
+		return false
+	}
+	diff := math.Abs(e - a)
+	return diff <= epsilon || diff <= epsilon*math.Abs(e)
+}
+
+// parseFinite parses token as a finite decimal number. Spellings such as
+// "inf", "nan" and hex floats are left to match as text.
+func parseFinite(token string) (float64, bool) {
+	if strings.ContainsAny(token, "xXnNiI_") {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(token, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+// lineSimilarity returns the percentage of line positions at which expected
+// and actual agree, each line compared with surrounding whitespace trimmed.
+// Missing or extra lines count as mismatches.
+func lineSimilarity(expected, actual string) float64 {
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+
+	total := len(expectedLines)
+	if len(actualLines) > total {
+		total = len(actualLines)
+	}
+
+	matched := 0
+	for i := 0; i < len(expectedLines) && i < len(actualLines); i++ {
+		if strings.TrimSpace(expectedLines[i]) == strings.TrimSpace(actualLines[i]) {
+			matched++
+		}
+	}
+	return float64(matched) * 100 / float64(total)
+}
+
+// firstMismatch walks the trimmed outputs once, character by character, and
+// stops at the first difference. Unlike normalizeOutput it never copies the
+// outputs, so a wrong answer on line 1 costs nothing however large they are.
+func firstMismatch(expected, actual string, ignoreCase bool) *Mismatch {
+	expected = strings.TrimSpace(expected)
+	actual = strings.TrimSpace(actual)
+
+	line, column := 1, 1
+	for len(expected) > 0 && len(actual) > 0 {
+		e, eSize := utf8.DecodeRuneInString(expected)
+		a, aSize := utf8.DecodeRuneInString(actual)
+		if e != a && !(ignoreCase && unicode.ToLower(e) == unicode.ToLower(a)) {
+			return &Mismatch{Line: line, Column: column}
+		}
+		expected, actual = expected[eSize:], actual[aSize:]
+
+		if e == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+
+	// One output is a prefix of the other
+	if len(expected) != len(actual) {
+		return &Mismatch{Line: line, Column: column}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCompareOutputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     CompareOptions
+		expected string
+		actual   string
+		want     bool
+	}{
+		// exact
+		{"exact match", CompareOptions{Mode: CompareExact}, "42", "42", true},
+		{"exact ignores surrounding whitespace", CompareOptions{Mode: CompareExact}, "42\n", "  42\n\n", true},
+		{"exact is the default mode", CompareOptions{}, "1 2", "1 2", true},
+		{"exact keeps inner spacing", CompareOptions{Mode: CompareExact}, "1 2", "1  2", false},
+		{"exact keeps line breaks", CompareOptions{Mode: CompareExact}, "1 2", "1\n2", false},
+		{"exact does not tolerate float error", CompareOptions{Mode: CompareExact}, "3.14159", "3.141590001", false},
+		{"exact with ignore_case", CompareOptions{Mode: CompareExact, IgnoreCase: true}, "YES", "yes", true},
+
+		// whitespace_insensitive
+		{"whitespace collapses spaces", CompareOptions{Mode: CompareWhitespace}, "1 2 3", "1   2\t3", true},
+		{"whitespace ignores line breaks", CompareOptions{Mode: CompareWhitespace}, "1 2\n3", "1\n2 3\n", true},
+		{"whitespace compares tokens exactly", CompareOptions{Mode: CompareWhitespace}, "1 2 3", "1 2 4", false},
+		{"whitespace does not tolerate float error", CompareOptions{Mode: CompareWhitespace}, "0.5", "0.50", false},
+		{"whitespace needs the same token count", CompareOptions{Mode: CompareWhitespace}, "1 2 3", "1 2", false},
+		{"whitespace rejects extra tokens", CompareOptions{Mode: CompareWhitespace}, "1 2", "1 2 3", false},
+		{"whitespace with ignore_case", CompareOptions{Mode: CompareWhitespace, IgnoreCase: true}, "Yes  No", "yes no", true},
+
+		// float, absolute error
+		{"float within default epsilon", CompareOptions{Mode: CompareFloat}, "3.14159", "3.141590001", true},
+		{"float beyond default epsilon", CompareOptions{Mode: CompareFloat}, "3.14159", "3.1416", false},
+		{"float equal spellings", CompareOptions{Mode: CompareFloat}, "0.5", "5e-1", true},
+		{"float within custom epsilon", CompareOptions{Mode: CompareFloat, Epsilon: 0.01}, "3.141", "3.14", true},
+		{"float beyond custom epsilon", CompareOptions{Mode: CompareFloat, Epsilon: 0.001}, "3.141", "3.13", false},
+		{"float near zero uses absolute error", CompareOptions{Mode: CompareFloat, Epsilon: 1e-3}, "0", "0.0009", true},
+		{"float integers", CompareOptions{Mode: CompareFloat}, "10", "10.0000001", true},
+
+		// float, relative error
+		{"float within relative epsilon", CompareOptions{Mode: CompareFloat}, "1000000", "1000000.5", true},
+		{"float beyond relative epsilon", CompareOptions{Mode: CompareFloat}, "1000000", "1000002", false},
+		{"float relative error of large values", CompareOptions{Mode: CompareFloat, Epsilon: 1e-9}, "1e20", "1.0000000001e20", true},
+
+		// float, non-numeric tokens
+		{"float text must match", CompareOptions{Mode: CompareFloat}, "Case #1: 0.5", "Case #1: 0.5000001", true},
+		{"float text mismatch", CompareOptions{Mode: CompareFloat}, "Case #1: 0.5", "case #1: 0.5", false},
+		{"float number for text", CompareOptions{Mode: CompareFloat}, "YES", "1", false},
+		{"float text for number", CompareOptions{Mode: CompareFloat}, "1", "one", false},
+		{"float with ignore_case", CompareOptions{Mode: CompareFloat, IgnoreCase: true}, "Area 2.5", "area 2.5000000001", true},
+
+		// float, token counts
+		{"float missing token", CompareOptions{Mode: CompareFloat}, "1.0 2.0", "1.0", false},
+		{"float extra token", CompareOptions{Mode: CompareFloat}, "1.0", "1.0 2.0", false},
+		{"float ignores line breaks", CompareOptions{Mode: CompareFloat}, "1.0\n2.0", "1.0 2.0000001", true},
+
+		// float, spellings parseFinite leaves to match as text
+		{"float inf matches itself", CompareOptions{Mode: CompareFloat}, "inf", "inf", true},
+		{"float inf spellings differ", CompareOptions{Mode: CompareFloat}, "inf", "Inf", false},
+		{"float inf is not a huge number", CompareOptions{Mode: CompareFloat}, "inf", "1e308", false},
+		{"float nan matches itself", CompareOptions{Mode: CompareFloat}, "nan", "nan", true},
+		{"float nan spellings differ", CompareOptions{Mode: CompareFloat}, "nan", "NaN", false},
+		{"float hex is text", CompareOptions{Mode: CompareFloat}, "0x10", "16", false},
+		{"float hex floats are text", CompareOptions{Mode: CompareFloat}, "0x1p-2", "0.25", false},
+		{"float overflow is text", CompareOptions{Mode: CompareFloat}, "1e400", "1e401", false},
+		{"float underscores are text", CompareOptions{Mode: CompareFloat}, "1_000", "1000", false},
+
+		// similarity, 3 of 4 lines (75%) matching
+		{"similarity exactly at threshold", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 75}, "1\n2\n3\n4", "1\n2\n3\nx", true},
+		{"similarity just below threshold", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 74.99}, "1\n2\n3\n4", "1\n2\n3\nx", true},
+		{"similarity just above threshold", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 75.01}, "1\n2\n3\n4", "1\n2\n3\nx", false},
+		// similarity, 2 of 3 lines matching, a percentage with no exact float
+		{"similarity at a repeating threshold", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 200.0 / 3}, "1\n2\n3", "1\nx\n3", true},
+		{"similarity below a repeating threshold", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 66.66}, "1\n2\n3", "1\nx\n3", true},
+		{"similarity above a repeating threshold", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 66.67}, "1\n2\n3", "1\nx\n3", false},
+		{"similarity missing line counts against", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 75}, "1\n2\n3\n4", "1\n2\n3", true},
+		{"similarity extra line counts against", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 80}, "1\n2\n3\n4", "1\n2\n3\n4\n5", true},
+		{"similarity extra line below threshold", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 80.01}, "1\n2\n3\n4", "1\n2\n3\n4\n5", false},
+		{"similarity of 100 needs every line", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 100}, "1\n2\n3\n4", "1\n2\n3\nx", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(); err != nil {
+				t.Fatalf("options %+v are invalid: %v", tt.opts, err)
+			}
+			got := compareOutputs(tt.expected, tt.actual, tt.opts)
+			if got.Passed != tt.want {
+				t.Errorf("compareOutputs(%q, %q, %+v) passed = %v, want %v", tt.expected, tt.actual, tt.opts, got.Passed, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareIgnoreCase(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		expected   string
+		actual     string
+		ignoreCase bool
+		want       bool
+	}{
+		{"exact is case sensitive by default", CompareExact, "YES", "yes", false, false},
+		{"exact folds case", CompareExact, "YES", "yes", true, true},
+		{"exact folds mixed case", CompareExact, "Hello World", "hELLO wORLD", true, true},
+		{"exact still compares letters", CompareExact, "YES", "no", true, false},
+		{"exact still compares spacing", CompareExact, "Hello World", "hello  world", true, false},
+		{"exact folds non-ascii", CompareExact, "ÉTÉ", "été", true, true},
+		{"first_diff is case sensitive by default", CompareFirstDiff, "Yes\nNo", "yes\nno", false, false},
+		{"first_diff folds case", CompareFirstDiff, "Yes\nNo", "yes\nno", true, true},
+		{"first_diff folds non-ascii", CompareFirstDiff, "ÉTÉ", "été", true, true},
+		{"first_diff still compares letters", CompareFirstDiff, "Yes", "Yet", true, false},
+		{"whitespace folds case", CompareWhitespace, "YES  NO", "yes\nno", true, true},
+		{"float folds case of text tokens", CompareFloat, "Case #1: 0.5", "case #1: 0.5000001", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := CompareOptions{Mode: tt.mode, IgnoreCase: tt.ignoreCase}
+			if got := compareOutputs(tt.expected, tt.actual, opts); got.Passed != tt.want {
+				t.Errorf("compareOutputs(%q, %q, %+v) passed = %v, want %v", tt.expected, tt.actual, opts, got.Passed, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstMismatchIgnoreCase(t *testing.T) {
+	mismatch := firstMismatch("Yes\nNo", "YES\nNot", true)
+	if mismatch == nil || mismatch.Line != 2 || mismatch.Column != 3 {
+		t.Errorf("firstMismatch = %+v, want line 2 column 3 past the case-only differences", mismatch)
+	}
+}
+
+func TestCompareOptionsValidateEpsilon(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    CompareOptions
+		wantErr bool
+	}{
+		{"float without epsilon", CompareOptions{Mode: CompareFloat}, false},
+		{"float with epsilon", CompareOptions{Mode: CompareFloat, Epsilon: 1e-9}, false},
+		{"negative epsilon", CompareOptions{Mode: CompareFloat, Epsilon: -1}, true},
+		{"epsilon outside float mode", CompareOptions{Mode: CompareExact, Epsilon: 0.1}, true},
+		{"epsilon with whitespace mode", CompareOptions{Mode: CompareWhitespace, Epsilon: 0.1}, true},
+		{"unknown mode", CompareOptions{Mode: "numeric"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOutputHashWhitespaceInsensitive(t *testing.T) {
+	opts := CompareOptions{Mode: CompareWhitespace}
+	if outputHash("1 2\n3", opts) != outputHash("1  2 3\n", opts) {
+		t.Errorf("outputs that grade the same in whitespace_insensitive mode hash differently")
+	}
+}
+
+func TestCompareRegex(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     CompareOptions
+		expected string
+		actual   string
+		want     bool
+	}{
+		{"matches", CompareOptions{Mode: CompareRegex}, `Case #\d+: \d+`, "Case #1: 42", true},
+		{"matches every line", CompareOptions{Mode: CompareRegex}, `(Case #\d+: \d+\n?)+`, "Case #1: 4\nCase #2: 5\n", true},
+		{"trailing whitespace is trimmed", CompareOptions{Mode: CompareRegex}, `\d+`, "42\n", true},
+		{"does not match", CompareOptions{Mode: CompareRegex}, `Case #\d+: \d+`, "Case #1: forty", false},
+		{"must match the whole output", CompareOptions{Mode: CompareRegex}, `\d+`, "42 43", false},
+		{"prefix is not enough", CompareOptions{Mode: CompareRegex}, `Case`, "Case #1", false},
+		{"alternation is anchored", CompareOptions{Mode: CompareRegex}, `YES|NO`, "NOPE", false},
+		{"case sensitive by default", CompareOptions{Mode: CompareRegex}, `yes`, "YES", false},
+		{"ignore_case", CompareOptions{Mode: CompareRegex, IgnoreCase: true}, `yes`, "YES", true},
+		{"invalid pattern never passes", CompareOptions{Mode: CompareRegex}, `(`, "(", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareOutputs(tt.expected, tt.actual, tt.opts)
+			if got.Passed != tt.want {
+				t.Errorf("compareOutputs(%q, %q) passed = %v, want %v", tt.expected, tt.actual, got.Passed, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpectedRegexInvalid(t *testing.T) {
+	for _, pattern := range []string{`(`, `[a-`, `a**`, `\`} {
+		if _, err := expectedRegex(pattern, false); err == nil {
+			t.Errorf("expectedRegex(%q) compiled, want an error", pattern)
+		}
+	}
+}
+
+func TestSubmitRejectsInvalidRegex(t *testing.T) {
+	req := SubmitRequest{TestCases: []TestCase{{ExpectedOutput: `\d+`}, {ExpectedOutput: `(\d+`}}}
+	req.Language, req.Code = "python", "print(1)"
+	req.Mode = CompareRegex
+
+	err := validateSubmitRequest(req)
+	if err == nil || !strings.Contains(err.Error(), "test_cases[1].expected_output: invalid regex") {
+		t.Errorf("validateSubmitRequest() = %v, want an invalid regex error for test_cases[1]", err)
+	}
+}
+
+func TestRegexCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	hot := `hot\d+`
+	hotRe, err := expectedRegex(hot, false)
+	if err != nil {
+		t.Fatalf("expectedRegex(%q): %v", hot, err)
+	}
+
+	// Fill the cache twice over, using the hot pattern throughout
+	for i := 0; i < 2*maxCachedRegexes; i++ {
+		if _, err := expectedRegex(fmt.Sprintf("cold%d", i), false); err != nil {
+			t.Fatal(err)
+		}
+		if i%100 == 0 {
+			expectedRegex(hot, false)
+		}
+	}
+
+	if re, _ := expectedRegex(hot, false); re != hotRe {
+		t.Errorf("the hot pattern was evicted and recompiled")
+	}
+	regexCache.Lock()
+	size, listed := len(regexCache.patterns), regexCache.recent.Len()
+	_, oldest := regexCache.patterns[`^(?:cold0)$`]
+	regexCache.Unlock()
+	if size != maxCachedRegexes || listed != maxCachedRegexes {
+		t.Errorf("cache holds %d patterns (%d listed), want %d", size, listed, maxCachedRegexes)
+	}
+	if oldest {
+		t.Errorf("the least recently used pattern was not evicted")
+	}
+}
@@ -3,284 +3,903 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"online-compiler/middleware"
 	"online-compiler/models"
 	"online-compiler/runner"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// config holds the runtime configuration used by the handlers
+var config = models.LoadConfig()
+
+// Configure sets the configuration used by the handlers
+func Configure(c *models.Config) {
+	config = c
+}
+
+// Server-side time limits for each endpoint, advertised to clients
+const (
+	executeTimeout = 20 * time.Second
+	submitTimeout  = 60 * time.Second // Longer to cover multiple test cases
+)
+
+// maxTestCases limits the test cases of a submission, generated ones included
+const maxTestCases = 100
+
+// setTimeoutHeader tells the client how long the server will spend on the request
+func setTimeoutHeader(w http.ResponseWriter, timeout time.Duration) {
+	w.Header().Set("X-Execution-Timeout-Ms", strconv.FormatInt(timeout.Milliseconds(), 10))
+}
+
+// executor runs the code submitted to the handlers
+var executor runner.Executor = runner.DockerExecutor{}
+
+// SetExecutor sets the executor used by the handlers
+func SetExecutor(e runner.Executor) {
+	executor = e
+}
+
 type ExecutionMetrics struct {
-	ExecutionTime float64 `json:"execution_time_ms"` // Time taken in milliseconds
-	MemoryUsed    int64   `json:"memory_used_kb"`    // Memory used in KB
+	ExecutionTime      float64 `json:"execution_time_ms"`        // Time taken in milliseconds
+	MemoryUsed         *int64  `json:"memory_used_kb,omitempty"` // Memory used in KB, omitted when stats are disabled
+	MemoryPeakBytes    int64   `json:"memory_peak_bytes"`        // Peak memory of the container
+	MemoryCurrentBytes int64   `json:"memory_current_bytes"`     // Memory of the container when the program exited
 }
 
 type ExecuteResponse struct {
-	Output    string           `json:"output"`
-	Error     string           `json:"error,omitempty"`
-	Status    string           `json:"status"`
-	Timestamp int64            `json:"timestamp"`
-	RequestID string           `json:"request_id,omitempty"`
-	Metrics   ExecutionMetrics `json:"metrics,omitempty"`
+	Output        string           `json:"output"`
+	CompileOutput string           `json:"compile_output"` // Empty for interpreted languages
+	RunStderr     string           `json:"run_stderr"`
+	BinarySize    int64            `json:"binary_size_bytes,omitempty"` // Omitted for interpreted languages
+	Error         string           `json:"error,omitempty"`
+	Status        string           `json:"status"`
+	Timestamp     int64            `json:"timestamp"`
+	RequestID     string           `json:"request_id,omitempty"`
+	Metrics       ExecutionMetrics `json:"metrics,omitempty"`
+	Profile       *runner.Profile  `json:"profile,omitempty"` // Only when requested and `time -v` is available
+	Cache         string           `json:"cache,omitempty"`   // "bypassed" when no_cache was set
+	Image         string           `json:"image,omitempty"`   // Sandbox image the program ran on
+	StackSizeMB   int              `json:"stack_size_mb"`     // Stack size limit applied to the run
 }
 
 func ExecuteHandler(w http.ResponseWriter, r *http.Request) {
-	// Set timeout context
-	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)  // Reduced from 30 to 20 seconds
-	defer cancel()
+	fields, err := parseFields(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var req models.ExecuteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	req = withIdentity(r, req)
+
+	// Set timeout context, allowing extra time for compiled languages
+	timeout := executeDeadline(req.Language)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	setTimeoutHeader(w, timeout)
 
 	// Validate request
-	if req.Language == "" || req.Code == "" {
-		http.Error(w, "Language and code are required", http.StatusBadRequest)
+	if req.Language == "" || (req.Code == "" && len(req.Files) == 0 && req.Artifact == nil) {
+		http.Error(w, "Language and code (or files, or an artifact) are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateRequest(req); err != nil {
+		sendRequestError(w, err)
+		return
+	}
+
+	if err := runner.ValidateEnv(req.Env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Resolve a previously uploaded input
+	input, err := resolveInputRef(req.Input, req.InputRef)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkControlChars("input", input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Input, req.InputRef = input, ""
+
 	// Start timing
 	startTime := time.Now()
 
 	// Execute code with timeout
-	output, err := runner.ExecuteInDocker(ctx, req)
+	result, err := executor.Execute(ctx, req)
 
 	// Calculate execution time
 	executionTime := time.Since(startTime).Seconds() * 1000 // Convert to milliseconds
 
+	// Report peak memory unless disabled for this request. It is read from the
+	// container's cgroup before the container exits, since --rm removes it.
+	var memoryUsed *int64
+	if runner.StatsEnabled(req.NoStats) {
+		memoryUsed = &result.Stats.MemoryUsed
+	}
+
 	if err != nil {
-		// Check if it's a timeout or rate limit error
-		if err.Error() == "request cancelled: context deadline exceeded" {
-			http.Error(w, "Request timed out", http.StatusGatewayTimeout)
-			return
+		// Report whatever was produced and measured before the failure
+		response := ExecuteResponse{
+			Output:        result.Output,
+			CompileOutput: result.CompileOutput,
+			RunStderr:     result.Stderr,
+			Status:        "error",
+			Error:         err.Error(),
+			Timestamp:     time.Now().Unix(),
+			RequestID:     requestID(r),
+			Metrics: ExecutionMetrics{
+				ExecutionTime:      executionTime,
+				MemoryUsed:         memoryUsed,
+				MemoryPeakBytes:    result.Stats.MemoryPeakBytes,
+				MemoryCurrentBytes: result.Stats.MemoryCurrentBytes,
+			},
+			Cache:       cacheStatus(req.NoCache),
+			StackSizeMB: runner.StackLimit(req.StackSizeMB),
 		}
-		if err.Error() == "server is busy, please try again later" {
-			http.Error(w, "Server is busy, please try again later", http.StatusTooManyRequests)
-			return
+		status := http.StatusInternalServerError
+		switch {
+		// Tell a timeout, which is the program's fault, apart from a cancellation
+		case errors.Is(err, context.DeadlineExceeded):
+			response.Status = "timeout"
+			status = http.StatusGatewayTimeout
+		case errors.Is(err, runner.ErrOutputLimitExceeded):
+			response.Status = "output_limit_exceeded"
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, context.Canceled):
+			response.Status = "cancelled"
+			response.Output = ""
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, runner.ErrWarmingUp):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, runner.ErrServerBusy):
+			w.Header().Set("Retry-After", strconv.Itoa(int(config.BusyRetryAfter.Seconds())))
+			status = http.StatusTooManyRequests
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStatusResponse(w, r, status, response, fields)
 		return
 	}
 
-	// Get container stats
-	containerStats, err := runner.GetContainerStats(ctx, req)
-	if err != nil {
-		// Log the error but continue with the response
-		fmt.Printf("Error getting container stats: %v\n", err)
-	}
-
 	// Prepare response
 	response := ExecuteResponse{
-		Output:    output,
-		Status:    "success",
-		Timestamp: time.Now().Unix(),
-		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+		Output:        result.Output,
+		CompileOutput: result.CompileOutput,
+		RunStderr:     result.Stderr,
+		BinarySize:    result.BinarySize,
+		Profile:       result.Profile,
+		Cache:         cacheStatus(req.NoCache),
+		Image:         result.Image,
+		StackSizeMB:   runner.StackLimit(req.StackSizeMB),
+		Status:        "success",
+		Timestamp:     time.Now().Unix(),
+		RequestID:     requestID(r),
 		Metrics: ExecutionMetrics{
-			ExecutionTime: executionTime,
-			MemoryUsed:    containerStats.MemoryUsed,
+			ExecutionTime:      executionTime,
+			MemoryUsed:         memoryUsed,
+			MemoryPeakBytes:    result.Stats.MemoryPeakBytes,
+			MemoryCurrentBytes: result.Stats.MemoryCurrentBytes,
 		},
 	}
 
-	// Log the response details
-	responseJSON, _ := json.MarshalIndent(response, "", "  ")
-	fmt.Printf("\n===== EXECUTE RESPONSE =====\n%s\n============================\n", string(responseJSON))
-
 	// Send response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeResponse(w, r, response, fields)
 }
 
 // TestCase represents a single test case for code submission
 type TestCase struct {
+	ID             string `json:"id,omitempty"`
 	Input          string `json:"input"`
 	ExpectedOutput string `json:"expected_output"`
+	TimeLimitMs    int    `json:"time_limit_ms,omitempty"`   // Overrides the default per-case timeout
+	MemoryLimitMB  int    `json:"memory_limit_mb,omitempty"` // Overrides the default container memory
+
+	generated bool // Input came from a generator, so there is no expected output
 }
 
 // SubmitRequest extends ExecuteRequest with test cases
 type SubmitRequest struct {
 	models.ExecuteRequest
+	CompareOptions
+	GeneratorSpec
 	TestCases []TestCase `json:"test_cases"`
+	Shards    int        `json:"shards,omitempty"` // Containers to split the test cases across
+	// Return and store a hash of each case's normalized output
+	HashOutputs bool `json:"hash_outputs,omitempty"`
 }
 
 // TestCaseResult represents the result of a single test case
 type TestCaseResult struct {
-	Input          string `json:"input"`
-	ExpectedOutput string `json:"expected_output"`
-	ActualOutput   string `json:"actual_output"`
-	Passed         bool   `json:"passed"`
+	ID             string         `json:"id,omitempty"`
+	Input          string         `json:"input"`
+	ExpectedOutput string         `json:"expected_output"`
+	ActualOutput   string         `json:"actual_output"`
+	Stderr         string         `json:"stderr,omitempty"` // Never compared against the expected output
+	Truncated      bool           `json:"truncated"`        // Output was cut at the per-case cap before comparison
+	Passed         bool           `json:"passed"`
+	Verdict        models.Verdict `json:"verdict"`
+	FirstMismatch  *Mismatch      `json:"first_mismatch,omitempty"` // Set in first_diff mode
+	Similarity     *float64       `json:"similarity,omitempty"`     // Percentage of matching lines, set in similarity mode
+	OutputHash     string         `json:"output_hash,omitempty"`    // sha256 of the normalized output, set with hash_outputs
+	// The strings actually compared, set when the case fails the comparison
+	NormalizedExpected *string `json:"normalized_expected,omitempty"`
+	NormalizedActual   *string `json:"normalized_actual,omitempty"`
+	// Time limit applied to the case after the language multiplier
+	TimeLimitMs int64 `json:"time_limit_ms"`
+	TimeMs      int64 `json:"time_ms"`             // Run time of the case inside the sandbox
+	MemoryKb    int64 `json:"memory_kb,omitempty"` // Container memory used, when stats are enabled
 }
 
 // SubmitResponse represents the response for a code submission
 type SubmitResponse struct {
-	Status       string          `json:"status"`
-	TotalCases   int             `json:"total_cases"`
-	PassedCases  int             `json:"passed_cases"`
-	Results      []TestCaseResult `json:"results"`
-	ExecutionTime float64        `json:"execution_time_ms"`
-	Timestamp    int64           `json:"timestamp"`
-	RequestID    string          `json:"request_id,omitempty"`
+	Status        string           `json:"status"`          // "error" when the backend failed to run the cases
+	Error         string           `json:"error,omitempty"` // Why the batch failed to run
+	Verdict       models.Verdict   `json:"verdict"`
+	CompileError  string           `json:"compile_error,omitempty"`
+	TotalCases    int              `json:"total_cases"`
+	PassedCases   int              `json:"passed_cases"`
+	Results       []TestCaseResult `json:"results"` // Empty when compilation failed
+	ExecutionTime float64          `json:"execution_time_ms"`
+	Timing        SubmitTiming     `json:"timing"`
+	MemoryPeak    int64            `json:"memory_peak_bytes"`           // Peak memory across all test cases
+	BinarySize    int64            `json:"binary_size_bytes,omitempty"` // Omitted for interpreted languages
+	Timestamp     int64            `json:"timestamp"`
+	RequestID     string           `json:"request_id,omitempty"`
+	RunnerScript  string           `json:"runner_script,omitempty"` // Only with DEBUG_RUNNER_SCRIPT in development
+	// Server-side path of the sandbox tarball, only with DEBUG_SANDBOX_ARCHIVE in development
+	SandboxArchive string `json:"sandbox_archive,omitempty"`
+	Cache          string `json:"cache,omitempty"` // "bypassed" when no_cache was set
+	Image          string `json:"image,omitempty"` // Sandbox image the cases ran on
+	StackSizeMB    int    `json:"stack_size_mb"`   // Stack size limit applied to every case
+}
+
+// SubmitTiming breaks a submission's execution time into its phases
+type SubmitTiming struct {
+	QueueWaitMs float64 `json:"queue_wait_ms"` // Waiting for a free container
+	CompileMs   float64 `json:"compile_ms"`    // Compiling the program
+	TotalRunMs  float64 `json:"total_run_ms"`  // Running all test cases
+	OverheadMs  float64 `json:"overhead_ms"`   // Container startup, file I/O and everything else
+}
+
+// newSubmitTiming derives the phase breakdown from the total time and the runner's measurements
+func newSubmitTiming(total time.Duration, timing runner.BatchMetrics) SubmitTiming {
+	overhead := total - timing.QueueWait - timing.Compile - timing.Run
+	if overhead < 0 {
+		overhead = 0
+	}
+	return SubmitTiming{
+		QueueWaitMs: milliseconds(timing.QueueWait),
+		CompileMs:   milliseconds(timing.Compile),
+		TotalRunMs:  milliseconds(timing.Run),
+		OverheadMs:  milliseconds(overhead),
+	}
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return d.Seconds() * 1000
 }
 
 func SubmitHandler(w http.ResponseWriter, r *http.Request) {
-	// Set timeout context
-	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second) // Increased timeout for multiple test cases
-	defer cancel()
+	fields, err := parseFields(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	var req SubmitRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, ok := decodeSubmitRequest(w, r)
+	if !ok {
 		return
 	}
 
-	// Log the request details
-	requestJSON, _ := json.MarshalIndent(req, "", "  ")
-	fmt.Printf("\n===== SUBMIT REQUEST =====\n%s\n==========================\n", string(requestJSON))
+	// Set timeout context, allowing extra time for compiled languages
+	timeout := submitDeadline(req)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	setTimeoutHeader(w, timeout)
 
-	// Validate request
-	if req.Language == "" || req.Code == "" {
-		http.Error(w, "Language and code are required", http.StatusBadRequest)
+	// Keep large submissions from starving interactive executions
+	release, ok := admitBatch(len(req.TestCases) + req.SeedCount)
+	if !ok {
+		sendBatchRejected(w)
 		return
 	}
+	defer release()
 
-	if len(req.TestCases) == 0 {
-		http.Error(w, "At least one test case is required", http.StatusBadRequest)
+	response, err := runSubmission(ctx, req, requestID(r))
+	if errors.Is(err, runner.ErrServerBusy) {
+		sendServerBusy(w)
 		return
 	}
-
-	// Limit the number of test cases to prevent abuse
-	maxTestCases := 100
-	if len(req.TestCases) > maxTestCases {
-		http.Error(w, fmt.Sprintf("Too many test cases. Maximum allowed: %d", maxTestCases), http.StatusBadRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
 
+	// Send response
+	writeResponse(w, r, response, fields)
+}
+
+// decodeSubmitRequest reads and validates a submission, writing the error
+// response and returning false when it cannot be graded
+func decodeSubmitRequest(w http.ResponseWriter, r *http.Request) (SubmitRequest, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return SubmitRequest{}, false
+	}
+
+	// Report every malformed field rather than a single decode error
+	if errs := validateSubmitPayload(body); len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return SubmitRequest{}, false
+	}
+
+	var req SubmitRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return SubmitRequest{}, false
+	}
+
+	// Validate request
+	if err := validateSubmitRequest(req); err != nil {
+		sendRequestError(w, err)
+		return SubmitRequest{}, false
+	}
+
+	if !runner.ImageReady() {
+		http.Error(w, runner.ErrWarmingUp.Error(), http.StatusServiceUnavailable)
+		return SubmitRequest{}, false
+	}
+	return req, true
+}
+
+// executeDeadline returns the time allowed to run an /execute request, allowing extra time for compiled languages
+func executeDeadline(language string) time.Duration {
+	return runner.ScaleTimeLimit(language, executeTimeout) + runner.CompileTimeout(language)
+}
+
+// submitDeadline returns the time allowed to grade req, allowing extra time
+// for compiled languages. Large batches get as long as their cases may take
+// when every case runs to its limit: each shard compiles and then runs its
+// cases one after another, and shards beyond MaxContainers wait for a slot.
+func submitDeadline(req SubmitRequest) time.Duration {
+	compile := runner.CompileTimeout(req.Language)
+	deadline := runner.ScaleTimeLimit(req.Language, submitTimeout) + compile
+
+	cases := len(req.TestCases) + req.SeedCount
+	if cases == 0 {
+		return deadline
+	}
+	// Generated cases run with the default limit
+	longest := runner.CaseTimeLimit(req.Language, 0)
+	for _, tc := range req.TestCases {
+		if limit := runner.CaseTimeLimit(req.Language, tc.TimeLimitMs); limit > longest {
+			longest = limit
+		}
+	}
+
+	shards := runner.BatchShards(req.Shards, cases)
+	if shards < 1 {
+		shards = 1
+	}
+	parallel := shards
+	if config.MaxContainers > 0 && parallel > config.MaxContainers {
+		parallel = config.MaxContainers
+	}
+	perShard := (cases + shards - 1) / shards
+	waves := (shards + parallel - 1) / parallel
+	if batch := time.Duration(waves) * (compile + time.Duration(perShard)*longest); batch > deadline {
+		deadline = batch
+	}
+	return deadline
+}
+
+// longestSubmission returns a submission in language that takes the longest
+// to grade: the most test cases, each at the maximum limit, in one shard
+func longestSubmission(language string) SubmitRequest {
+	req := SubmitRequest{TestCases: make([]TestCase, maxTestCases), Shards: 1}
+	req.Language = language
+	for i := range req.TestCases {
+		req.TestCases[i].TimeLimitMs = int(config.MaxCaseTimeLimit.Milliseconds())
+	}
+	return req
+}
+
+// MaxRequestTimeout returns the longest time any handler may spend on a
+// request, so the server's write deadline can be set beyond it
+func MaxRequestTimeout() time.Duration {
+	longest := submitTimeout // /execute/bulk
+	if config.StressTimeout > longest {
+		longest = config.StressTimeout
+	}
+	for _, language := range runner.SupportedLanguages() {
+		submit := submitDeadline(longestSubmission(language))
+		for _, timeout := range []time.Duration{executeDeadline(language), submit} {
+			if timeout > longest {
+				longest = timeout
+			}
+		}
+	}
+	return longest
+}
+
+// runSubmission generates any extra test cases, then runs and grades req.
+// It returns an error only when the submission could not be graded at all:
+// runner.ErrServerBusy when the runner is at capacity, or a generator failure.
+func runSubmission(ctx context.Context, req SubmitRequest, id string) (SubmitResponse, error) {
 	// Start timing
 	startTime := time.Now()
 
-	// Process test cases in batches
+	// Add the test cases produced by the input generator
+	if req.GeneratorCode != "" {
+		generated, err := generateCases(ctx, req.GeneratorSpec)
+		if err != nil {
+			return SubmitResponse{}, err
+		}
+		req.TestCases = append(req.TestCases, generated...)
+	}
+
+	// Run and grade all test cases
+	outcome := gradeCases(ctx, req)
+	if errors.Is(outcome.Err, runner.ErrServerBusy) {
+		return SubmitResponse{}, outcome.Err
+	}
+
+	if req.HashOutputs && outcome.Err == nil {
+		storeOutputHashes(id, req, outcome.Results)
+	}
+
+	// Calculate execution time
+	totalTime := time.Since(startTime)
+	executionTime := totalTime.Seconds() * 1000 // Convert to milliseconds
+
+	// Prepare response
+	return SubmitResponse{
+		Status:         outcome.status(),
+		Error:          outcome.errorMessage(),
+		Verdict:        outcome.verdict(),
+		CompileError:   outcome.CompileError,
+		TotalCases:     len(req.TestCases),
+		PassedCases:    outcome.PassedCount,
+		Results:        outcome.Results,
+		ExecutionTime:  executionTime,
+		Timing:         newSubmitTiming(totalTime, outcome.Metrics),
+		MemoryPeak:     outcome.Metrics.Memory.MemoryPeakBytes,
+		BinarySize:     outcome.Metrics.BinarySize,
+		Timestamp:      time.Now().Unix(),
+		RequestID:      id,
+		RunnerScript:   outcome.Metrics.Script,
+		SandboxArchive: outcome.Metrics.Archive,
+		Cache:          cacheStatus(req.NoCache),
+		Image:          outcome.Metrics.Image,
+		StackSizeMB:    runner.StackLimit(req.StackSizeMB),
+	}, nil
+}
+
+// gradeOutcome holds the graded results of running a submission's test cases
+type gradeOutcome struct {
+	Results      []TestCaseResult
+	PassedCount  int
+	CompileError string
+	Metrics      runner.BatchMetrics
+	Err          error // Set when the batch as a whole failed to run
+}
+
+// status returns the top-level response status: "error" when the batch
+// itself failed to run, as opposed to cases that ran and failed
+func (o gradeOutcome) status() string {
+	if o.Err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// errorMessage describes why the batch failed to run, if it did
+func (o gradeOutcome) errorMessage() string {
+	if o.Err == nil {
+		return ""
+	}
+	return o.Err.Error()
+}
+
+// verdict returns the overall verdict for the outcome
+func (o gradeOutcome) verdict() models.Verdict {
+	if o.CompileError != "" {
+		return models.VerdictCompileError
+	}
+	return overallVerdict(o.Results)
+}
+
+// gradeCases runs all of a submission's test cases in a single container and grades each result
+func gradeCases(ctx context.Context, req SubmitRequest) gradeOutcome {
 	results := make([]TestCaseResult, len(req.TestCases))
 	passedCount := 0
 
 	// Create a batch execution request
 	batchReq := models.BatchExecuteRequest{
-		Code:      req.Code,
-		Language:  req.Language,
-		TestCases: make([]models.TestInput, len(req.TestCases)),
+		Code:        req.Code,
+		Language:    req.Language,
+		TestCases:   make([]models.TestInput, len(req.TestCases)),
+		Env:         req.Env,
+		NoStats:     req.NoStats,
+		Shards:      req.Shards,
+		Artifact:    req.Artifact,
+		Files:       req.Files,
+		NoCache:     req.NoCache,
+		Version:     req.Version,
+		StackSizeMB: req.StackSizeMB,
 	}
 
 	// Prepare test cases for batch execution
 	for i, tc := range req.TestCases {
 		batchReq.TestCases[i] = models.TestInput{
-			ID:    fmt.Sprintf("tc_%d", i),
-			Input: tc.Input,
+			ID:            fmt.Sprintf("tc_%d", i),
+			Input:         tc.Input,
+			TimeLimitMs:   tc.TimeLimitMs,
+			MemoryLimitMB: tc.MemoryLimitMB,
 		}
 	}
 
-	// Execute all test cases in a single container
-	batchResults, err := runner.ExecuteBatchInDocker(ctx, batchReq)
-	
+	// Execute all test cases, sharded across containers if requested
+	batchResults, metrics, err := executor.ExecuteBatch(ctx, batchReq)
+
+	var compileError string
 	if err != nil {
+		if errors.Is(err, runner.ErrServerBusy) {
+			return gradeOutcome{Err: err}
+		}
 		// If the entire batch failed, mark all test cases as failed
 		for i, tc := range req.TestCases {
 			results[i] = TestCaseResult{
+				ID:             tc.ID,
 				Input:          tc.Input,
 				ExpectedOutput: tc.ExpectedOutput,
 				ActualOutput:   fmt.Sprintf("Execution error: %v", err),
 				Passed:         false,
+				Verdict:        models.VerdictSystemError,
+				TimeLimitMs:    runner.CaseTimeLimit(req.Language, tc.TimeLimitMs).Milliseconds(),
 			}
 		}
+	} else if len(batchResults) > 0 && batchResults[0].Verdict == models.VerdictCompileError {
+		// Compilation failed, so no case ran; report the error once
+		compileError = strings.TrimPrefix(batchResults[0].Output, "Compilation error: ")
+		results = []TestCaseResult{}
 	} else {
-		// Process results for each test case
+		// Match results by ID so a missing one cannot shift the rest
+		byID := make(map[string]runner.BatchResult, len(batchResults))
+		for _, batch := range batchResults {
+			byID[batch.ID] = batch
+		}
 		for i, tc := range req.TestCases {
+			batch, ok := byID[batchReq.TestCases[i].ID]
+			if !ok {
+				// Never grade a missing result as empty output
+				batch = runner.BatchResult{
+					Output:  "Execution error: no result was produced for this test case",
+					Verdict: models.VerdictSystemError,
+				}
+			}
+
 			result := TestCaseResult{
+				ID:             tc.ID,
 				Input:          tc.Input,
 				ExpectedOutput: tc.ExpectedOutput,
-				ActualOutput:   batchResults[fmt.Sprintf("tc_%d", i)],
+				ActualOutput:   batch.Output,
+				Stderr:         batch.Stderr,
+				Truncated:      batch.Truncated,
 				Passed:         false,
+				TimeLimitMs:    runner.CaseTimeLimit(req.Language, tc.TimeLimitMs).Milliseconds(),
+				TimeMs:         batch.TimeMs,
+				MemoryKb:       batch.MemKb,
 			}
 
 			// Check for timeout or error in this specific test case
-			if strings.Contains(result.ActualOutput, "execution timed out") {
-				result.ActualOutput = "Execution timed out. Your code may contain an infinite loop."
+			if batch.Verdict != "" {
+				result.Verdict = batch.Verdict
+				// Keep the more specific message for a program stuck waiting for input
+				if batch.Verdict == models.VerdictTimeLimitExceeded && !strings.Contains(result.ActualOutput, "waiting for input") {
+					result.ActualOutput = "Execution timed out. Your code may contain an infinite loop."
+				}
+			} else if tc.generated {
+				// Generated cases have no expected output; running cleanly passes
+				result.Passed = true
+				result.Verdict = models.VerdictAccepted
+				passedCount++
 			} else {
-				// Normalize outputs for comparison
-				normalizedExpected := strings.TrimSpace(tc.ExpectedOutput)
-				normalizedActual := strings.TrimSpace(result.ActualOutput)
-				
-				// Remove trailing newlines that might be added by different languages
-				normalizedExpected = strings.TrimRight(normalizedExpected, "\n\r")
-				normalizedActual = strings.TrimRight(normalizedActual, "\n\r")
-				
+				if req.HashOutputs {
+					result.OutputHash = outputHash(result.ActualOutput, req.CompareOptions)
+				}
+
 				// Check if output matches expected output
-				if normalizedActual == normalizedExpected {
+				outcome := compareOutputs(tc.ExpectedOutput, result.ActualOutput, req.CompareOptions)
+				result.FirstMismatch = outcome.Mismatch
+				result.Similarity = outcome.Similarity
+				if outcome.Passed {
 					result.Passed = true
+					result.Verdict = models.VerdictAccepted
 					passedCount++
+				} else {
+					result.Verdict = models.VerdictWrongAnswer
+					// Show exactly what the comparator saw
+					expected := normalizeOutput(tc.ExpectedOutput, req.CompareOptions)
+					actual := normalizeOutput(result.ActualOutput, req.CompareOptions)
+					result.NormalizedExpected = &expected
+					result.NormalizedActual = &actual
 				}
 			}
-			
+
 			results[i] = result
 		}
 	}
 
-	// Calculate execution time
-	executionTime := time.Since(startTime).Seconds() * 1000 // Convert to milliseconds
+	return gradeOutcome{
+		Results:      results,
+		PassedCount:  passedCount,
+		CompileError: compileError,
+		Metrics:      metrics,
+		Err:          err,
+	}
+}
 
-	// Prepare response
-	response := SubmitResponse{
-		Status:        "success",
-		TotalCases:    len(req.TestCases),
-		PassedCases:   passedCount,
-		Results:       results,
-		ExecutionTime: executionTime,
-		Timestamp:     time.Now().Unix(),
-		RequestID:     fmt.Sprintf("%d", time.Now().UnixNano()),
+// validateSubmitRequest checks a decoded submission before any test case runs
+func validateSubmitRequest(req SubmitRequest) error {
+	if req.Language == "" || (req.Code == "" && len(req.Files) == 0 && req.Artifact == nil) {
+		return fmt.Errorf("Language and code (or files, or an artifact) are required")
 	}
 
-	// Log the response details
-	responseJSON, _ := json.MarshalIndent(response, "", "  ")
-	fmt.Printf("\n===== SUBMIT RESPONSE =====\n%s\n===========================\n", string(responseJSON))
+	if err := validateRequest(req.ExecuteRequest); err != nil {
+		return err
+	}
 
-	// Send response
+	if err := runner.ValidateEnv(req.Env); err != nil {
+		return err
+	}
+
+	if err := req.CompareOptions.validate(); err != nil {
+		return err
+	}
+
+	if req.Shards < 0 || req.Shards > config.MaxBatchShards {
+		return fmt.Errorf("shards must be between 0 and %d", config.MaxBatchShards)
+	}
+
+	if err := req.GeneratorSpec.validate(); err != nil {
+		return err
+	}
+
+	if len(req.TestCases)+req.SeedCount == 0 {
+		return fmt.Errorf("At least one test case is required")
+	}
+
+	// Limit the number of test cases to prevent abuse
+	if len(req.TestCases)+req.SeedCount > maxTestCases {
+		return fmt.Errorf("Too many test cases. Maximum allowed: %d", maxTestCases)
+	}
+
+	// All inputs of a batch are written to the same sandbox, so their total is limited
+	totalInput := 0
+	for _, tc := range req.TestCases {
+		totalInput += len(tc.Input)
+	}
+	if limit := config.InputSizeLimit(req.Language); totalInput > limit {
+		return fmt.Errorf("total test case input size of %d bytes exceeds maximum limit of %d bytes for %s", totalInput, limit, req.Language)
+	}
+
+	// Validate per-case limits and inputs
+	for i, tc := range req.TestCases {
+		if err := validateCaseLimits(tc); err != nil {
+			return fmt.Errorf("test_cases[%d]: %v", i, err)
+		}
+		if err := checkControlChars(fmt.Sprintf("test_cases[%d].input", i), tc.Input); err != nil {
+			return err
+		}
+		if req.Mode == CompareRegex {
+			if _, err := expectedRegex(tc.ExpectedOutput, req.IgnoreCase); err != nil {
+				return fmt.Errorf("test_cases[%d].expected_output: invalid regex: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// storeOutputHashes persists the output hashes of a graded submission for
+// later integrity analysis. Failing to store them does not fail the submission.
+func storeOutputHashes(submissionID string, req SubmitRequest, results []TestCaseResult) {
+	var hashes []runner.OutputHash
+	for i, result := range results {
+		if result.OutputHash == "" {
+			continue
+		}
+		caseID := result.ID
+		if caseID == "" {
+			caseID = fmt.Sprintf("tc_%d", i)
+		}
+		hashes = append(hashes, runner.OutputHash{
+			SubmissionID: submissionID,
+			CaseID:       caseID,
+			Language:     req.Language,
+			Hash:         result.OutputHash,
+			CreatedAt:    time.Now(),
+		})
+	}
+	if len(hashes) == 0 {
+		return
+	}
+	if err := runner.SaveOutputHashes(hashes); err != nil {
+		log.Printf("[ERROR] Failed to store output hashes for %s: %v", submissionID, err)
+	}
+}
+
+// overallVerdict returns the verdict of the first failing test case, or AC if all passed
+func overallVerdict(results []TestCaseResult) models.Verdict {
+	for _, result := range results {
+		if result.Verdict != models.VerdictAccepted {
+			return result.Verdict
+		}
+	}
+	return models.VerdictAccepted
+}
+
+// validateCaseLimits checks a test case's limit overrides against the configured caps
+func validateCaseLimits(tc TestCase) error {
+	if tc.TimeLimitMs < 0 || time.Duration(tc.TimeLimitMs)*time.Millisecond > config.MaxCaseTimeLimit {
+		return fmt.Errorf("time_limit_ms must be between 0 and %d", config.MaxCaseTimeLimit.Milliseconds())
+	}
+	if tc.MemoryLimitMB < 0 || tc.MemoryLimitMB > config.MaxCaseMemoryLimit {
+		return fmt.Errorf("memory_limit_mb must be between 0 and %d", config.MaxCaseMemoryLimit)
+	}
+	return nil
+}
+
+// LanguageErrorResponse is returned for an unsupported or disabled language
+// so clients can retry with one of the languages currently accepted
+type LanguageErrorResponse struct {
+	Status             string   `json:"status"`
+	Error              string   `json:"error"`
+	SupportedLanguages []string `json:"supported_languages"`
+	Timestamp          int64    `json:"timestamp"`
+}
+
+// sendRequestError writes a validation error. Language errors get a JSON body
+// listing the enabled languages; other errors are sent as plain text.
+func sendRequestError(w http.ResponseWriter, err error) {
+	status := validationStatus(err)
+	if !errors.Is(err, runner.ErrUnsupportedLanguage) && !errors.Is(err, runner.ErrLanguageDisabled) {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	supported := runner.EnabledLanguages()
+	if supported == nil {
+		supported = []string{}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(LanguageErrorResponse{
+		Status:             "error",
+		Error:              err.Error(),
+		SupportedLanguages: supported,
+		Timestamp:          time.Now().Unix(),
+	})
+}
+
+// validationStatus returns the HTTP status for a request validation error
+func validationStatus(err error) int {
+	if errors.Is(err, runner.ErrLanguageDisabled) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, runner.ErrBannedPattern) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
 }
 
 func validateRequest(req models.ExecuteRequest) error {
 	// Check language
-	switch req.Language {
-	case "python", "java", "cpp", "c", "javascript", "go":
-		// Valid language
-	default:
-		return fmt.Errorf("unsupported language: %s", req.Language)
+	if !runner.LanguageSupported(req.Language) {
+		return fmt.Errorf("%w: %s", runner.ErrUnsupportedLanguage, req.Language)
+	}
+	if !runner.LanguageEnabled(req.Language) {
+		return fmt.Errorf("%w: %s", runner.ErrLanguageDisabled, req.Language)
 	}
 
-	// Check code size
-	if len(req.Code) == 0 {
+	if _, err := runner.ResolveImage(req.Language, req.Version); err != nil {
+		return err
+	}
+	if req.StackSizeMB < 0 {
+		return fmt.Errorf("stack_size_mb cannot be negative")
+	}
+
+	// A precompiled artifact replaces the code
+	if req.Artifact != nil {
+		if len(req.Files) > 0 {
+			return fmt.Errorf("files cannot be combined with an artifact")
+		}
+		if err := runner.ValidateArtifact(req.Language, req.Artifact); err != nil {
+			return err
+		}
+	} else if len(req.Files) > 0 {
+		if err := runner.CheckFiles(req.Language, req.Code, req.Files); err != nil {
+			return err
+		}
+	} else if len(req.Code) == 0 {
 		return fmt.Errorf("code cannot be empty")
 	}
-	if len(req.Code) > 1024*1024 { // 1MB limit
-		return fmt.Errorf("code size exceeds maximum limit of 1MB")
+
+	// Check code size, counting every source file
+	if limit := config.CodeSizeLimit(req.Language); runner.SourceSize(req.Code, req.Files) > limit {
+		return fmt.Errorf("code size exceeds maximum limit of %d bytes for %s", limit, req.Language)
 	}
 
-	// Additional validation for submissions
-	if req.Input != "" && len(req.Input) > 1024*1024 { // 1MB limit for input
-		return fmt.Errorf("input size exceeds maximum limit of 1MB")
+	// Check input size
+	if limit := config.InputSizeLimit(req.Language); len(req.Input) > limit {
+		return fmt.Errorf("input size exceeds maximum limit of %d bytes for %s", limit, req.Language)
+	}
+
+	// Reject bytes that corrupt written files or confuse shells
+	if err := checkControlChars("code", req.Code); err != nil {
+		return err
+	}
+	if err := checkControlChars("input", req.Input); err != nil {
+		return err
+	}
+
+	if err := runner.CheckSource(req.Language, req.Code); err != nil {
+		return err
+	}
+
+	// Reject code matching the configured banned patterns
+	if err := runner.ScanCode(req.Language, req.Code); err != nil {
+		return err
+	}
+
+	for _, file := range req.Files {
+		if err := checkControlChars(file.Path, file.Content); err != nil {
+			return err
+		}
+		if err := runner.ScanCode(req.Language, file.Content); err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
 	}
 
 	return nil
 }
 
-func sendErrorResponse(w http.ResponseWriter, message string, status int, requestID string) {
-	response := ExecuteResponse{
-		Status:    "error",
-		Error:     message,
-		Timestamp: time.Now().Unix(),
-		RequestID: requestID,
+// withIdentity attributes req to the tenant and user IdentityMiddleware
+// attached to r, so its stats are recorded against them
+func withIdentity(r *http.Request, req models.ExecuteRequest) models.ExecuteRequest {
+	id, _ := middleware.IdentityFrom(r.Context())
+	req.TenantID, req.UserID = id.TenantID, id.UserID
+	return req
+}
+
+// requestID returns the ID assigned by RequestIDMiddleware, generating one if it is missing
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); id != "" {
+		return id
 	}
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(response)
+	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
 
+// cacheStatus returns the cache field of a response for a request with the given no_cache flag
+func cacheStatus(noCache bool) string {
+	if noCache {
+		return "bypassed"
+	}
+	return ""
+}
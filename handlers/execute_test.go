@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"online-compiler/middleware"
+	"online-compiler/models"
+	"online-compiler/runner"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeExecutor returns canned results instead of running code
+type fakeExecutor struct {
+	result       runner.ExecutionResult
+	err          error
+	batchResults []runner.BatchResult
+	batchMetrics runner.BatchMetrics
+	batchErr     error
+}
+
+func (f fakeExecutor) Execute(ctx context.Context, req models.ExecuteRequest) (runner.ExecutionResult, error) {
+	return f.result, f.err
+}
+
+func (f fakeExecutor) ExecuteBatch(ctx context.Context, req models.BatchExecuteRequest) ([]runner.BatchResult, runner.BatchMetrics, error) {
+	return f.batchResults, f.batchMetrics, f.batchErr
+}
+
+// useExecutor makes the handlers run code with e until the test ends
+func useExecutor(t *testing.T, e runner.Executor) {
+	previous := executor
+	SetExecutor(e)
+	t.Cleanup(func() { SetExecutor(previous) })
+}
+
+// useConfig configures the handlers and runner with c until the test ends
+func useConfig(t *testing.T, c *models.Config) {
+	previous := config
+	runner.Configure(c)
+	Configure(c)
+	t.Cleanup(func() {
+		runner.Configure(previous)
+		Configure(previous)
+	})
+}
+
+func TestMaxRequestTimeoutCoversEveryDeadline(t *testing.T) {
+	c := models.LoadConfig()
+	c.TimeLimitMultipliers = map[string]float64{"java": 3, "python": 0.5}
+	useConfig(t, c)
+
+	longest := MaxRequestTimeout()
+	for _, language := range runner.SupportedLanguages() {
+		if d := executeDeadline(language); d > longest {
+			t.Errorf("%s execute deadline %v exceeds MaxRequestTimeout %v", language, d, longest)
+		}
+		if d := submitDeadline(longestSubmission(language)); d > longest {
+			t.Errorf("%s submit deadline %v exceeds MaxRequestTimeout %v", language, d, longest)
+		}
+	}
+	if longest < config.StressTimeout {
+		t.Errorf("MaxRequestTimeout %v is shorter than the stress timeout %v", longest, config.StressTimeout)
+	}
+
+	// java's submit deadline is three times the base plus its compile timeout
+	want := 3*submitTimeout + runner.CompileTimeout("java")
+	if longest < want {
+		t.Errorf("MaxRequestTimeout = %v, want at least %v", longest, want)
+	}
+}
+
+func TestSubmitDeadlineCoversEveryCase(t *testing.T) {
+	c := models.LoadConfig()
+	c.MaxContainers = 4
+	c.BatchShards = 1
+	c.MaxCaseTimeLimit = 10 * time.Second
+	useConfig(t, c)
+
+	cases := func(n, limitMs int) []TestCase {
+		tcs := make([]TestCase, n)
+		for i := range tcs {
+			tcs[i].TimeLimitMs = limitMs
+		}
+		return tcs
+	}
+	compile := runner.CompileTimeout("cpp")
+	tests := []struct {
+		name    string
+		req     SubmitRequest
+		atLeast time.Duration
+	}{
+		{"small batch keeps the base timeout", SubmitRequest{TestCases: cases(3, 0)}, submitTimeout + compile},
+		{"sequential cases", SubmitRequest{TestCases: cases(100, 10000)}, 100*10*time.Second + compile},
+		{"generated cases use the default limit", SubmitRequest{TestCases: cases(1, 0), GeneratorSpec: GeneratorSpec{SeedCount: 99}}, 100*runner.CaseTimeLimit("cpp", 0) + compile},
+		{"parallel shards", SubmitRequest{TestCases: cases(100, 10000), Shards: 4}, 25*10*time.Second + compile},
+		{"shards waiting for a container", SubmitRequest{TestCases: cases(100, 10000), Shards: 8}, 2 * (13*10*time.Second + compile)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Language = "cpp"
+			if d := submitDeadline(tt.req); d < tt.atLeast {
+				t.Errorf("submitDeadline() = %v, want at least %v", d, tt.atLeast)
+			}
+		})
+	}
+}
+
+func TestSubmitCompileErrorReportsEmptyResults(t *testing.T) {
+	compileError := runner.BatchResult{Output: "Compilation error: main.c:1: error: expected ';'", Verdict: models.VerdictCompileError}
+	useExecutor(t, fakeExecutor{batchResults: []runner.BatchResult{compileError, compileError}})
+
+	req := SubmitRequest{TestCases: []TestCase{{Input: "1", ExpectedOutput: "1"}, {Input: "2", ExpectedOutput: "2"}}}
+	req.Language, req.Code = "c", "int main() { return 0 }"
+	response, err := runSubmission(context.Background(), req, "test")
+	if err != nil {
+		t.Fatalf("runSubmission: %v", err)
+	}
+
+	if response.Verdict != models.VerdictCompileError || response.CompileError != "main.c:1: error: expected ';'" {
+		t.Errorf("verdict %q, compile error %q; want CE reported once", response.Verdict, response.CompileError)
+	}
+	data, _ := json.Marshal(response)
+	if !strings.Contains(string(data), `"results":[]`) {
+		t.Errorf("response results are not an empty array: %s", data)
+	}
+}
+
+func TestGradeCasesMissingResult(t *testing.T) {
+	// The executor loses the middle case's result
+	useExecutor(t, fakeExecutor{batchResults: []runner.BatchResult{
+		{ID: "tc_0", Output: "1\n"},
+		{ID: "tc_2", Output: "3\n"},
+	}})
+
+	req := SubmitRequest{TestCases: []TestCase{
+		{ID: "a", Input: "1", ExpectedOutput: "1"},
+		{ID: "b", Input: "2", ExpectedOutput: "2"},
+		{ID: "c", Input: "3", ExpectedOutput: "3"},
+	}}
+	req.Language, req.Code = "python", "print(input())"
+	outcome := gradeCases(context.Background(), req)
+
+	want := []models.Verdict{models.VerdictAccepted, models.VerdictSystemError, models.VerdictAccepted}
+	for i, result := range outcome.Results {
+		if result.Verdict != want[i] {
+			t.Errorf("case %s verdict %q, want %q", result.ID, result.Verdict, want[i])
+		}
+	}
+	if missing := outcome.Results[1]; missing.Passed || !strings.Contains(missing.ActualOutput, "no result was produced") {
+		t.Errorf("missing case graded as %+v, want a system error", missing)
+	}
+	if outcome.PassedCount != 2 {
+		t.Errorf("passed %d cases, want 2", outcome.PassedCount)
+	}
+}
+
+func TestExecuteErrorReportsMemoryUsed(t *testing.T) {
+	c := models.LoadConfig()
+	c.CollectContainerStats = true
+	useConfig(t, c)
+	stats := runner.ContainerStats{MemoryUsed: 2048, MemoryPeakBytes: 2048 * 1024}
+	useExecutor(t, fakeExecutor{result: runner.ExecutionResult{Stats: stats}, err: runner.ErrOutputLimitExceeded})
+
+	body := `{"language": "python", "code": "while True: print(1)"}`
+	w := httptest.NewRecorder()
+	ExecuteHandler(w, httptest.NewRequest(http.MethodPost, "/execute", strings.NewReader(body)))
+
+	var response ExecuteResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if response.Status != "output_limit_exceeded" {
+		t.Fatalf("status = %q, want output_limit_exceeded", response.Status)
+	}
+	if response.Metrics.MemoryUsed == nil || *response.Metrics.MemoryUsed != 2048 {
+		t.Errorf("memory_used_kb = %v, want the 2048 KB the probe collected", response.Metrics.MemoryUsed)
+	}
+}
+
+func TestExecuteErrorHonoursFields(t *testing.T) {
+	useExecutor(t, fakeExecutor{err: runner.ErrOutputLimitExceeded})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string // Optional keys expected in the response
+		omit  []string // Optional keys expected to be left out
+	}{
+		{"every field", "", []string{"metrics", "request_id"}, nil},
+		{"metrics only", "?fields=metrics", []string{"metrics"}, []string{"request_id"}},
+		{"request_id only", "?fields=request_id", []string{"request_id"}, []string{"metrics"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"language": "python", "code": "while True: print(1)"}`
+			req := httptest.NewRequest(http.MethodPost, "/execute"+tt.query, strings.NewReader(body))
+			req.Header.Set("X-Request-ID", "req-1")
+			w := httptest.NewRecorder()
+			ExecuteHandler(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("HTTP status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+			}
+			var response map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if response["status"] != "output_limit_exceeded" || response["error"] == nil {
+				t.Errorf("response %v lacks the error status and message", response)
+			}
+			for _, key := range tt.want {
+				if _, ok := response[key]; !ok {
+					t.Errorf("response lacks %q: %v", key, response)
+				}
+			}
+			for _, key := range tt.omit {
+				if _, ok := response[key]; ok {
+					t.Errorf("response has %q, which fields left out: %v", key, response)
+				}
+			}
+		})
+	}
+}
+
+// recordingExecutor records the requests it is asked to execute
+type recordingExecutor struct {
+	fakeExecutor
+	requests chan models.ExecuteRequest
+}
+
+func (e recordingExecutor) Execute(ctx context.Context, req models.ExecuteRequest) (runner.ExecutionResult, error) {
+	e.requests <- req
+	return e.fakeExecutor.Execute(ctx, req)
+}
+
+func TestExecuteCarriesIdentity(t *testing.T) {
+	executed := recordingExecutor{requests: make(chan models.ExecuteRequest, 1)}
+	useExecutor(t, executed)
+	gateway, _ := middleware.ParseTrustedProxies([]string{"192.0.2.1"})
+	handler := middleware.IdentityMiddleware(gateway, "X-Tenant-ID", "X-User-ID")(http.HandlerFunc(ExecuteHandler))
+
+	// Identity fields in the body are ignored
+	body := `{"language": "python", "code": "print(1)", "TenantID": "spoofed", "UserID": "spoofed"}`
+	r := httptest.NewRequest(http.MethodPost, "/execute", strings.NewReader(body))
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Tenant-ID", "acme")
+	r.Header.Set("X-User-ID", "alice")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	req := <-executed.requests
+	if req.TenantID != "acme" || req.UserID != "alice" {
+		t.Errorf("executed request has tenant %q and user %q, want acme and alice", req.TenantID, req.UserID)
+	}
+
+	// Without a trusted gateway there is no identity
+	r = httptest.NewRequest(http.MethodPost, "/execute", strings.NewReader(body))
+	r.RemoteAddr = "198.51.100.7:1234"
+	r.Header.Set("X-Tenant-ID", "acme")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if req := <-executed.requests; req.TenantID != "" || req.UserID != "" {
+		t.Errorf("untrusted request was attributed to tenant %q and user %q", req.TenantID, req.UserID)
+	}
+}
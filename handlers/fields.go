@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// optionalSections maps each response section a client may leave out to the
+// JSON keys it covers. Top-level keys are dropped from the response; keys
+// prefixed with "results." are dropped from every per-case result.
+var optionalSections = map[string][]string{
+	"metrics":         {"metrics", "timing", "execution_time_ms", "memory_peak_bytes"},
+	"request_id":      {"request_id"},
+	"input":           {"results.input"},
+	"expected_output": {"results.expected_output"},
+}
+
+// responseFields is the set of optional sections a client asked for; nil means all of them
+type responseFields map[string]bool
+
+// parseFields reads the "fields" query parameter, a comma-separated list of
+// optional sections to include. Without it every section is included.
+func parseFields(r *http.Request) (responseFields, error) {
+	raw := r.URL.Query().Get("fields")
+	if raw == "" {
+		return nil, nil
+	}
+	fields := make(responseFields)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := optionalSections[name]; !ok {
+			return nil, fmt.Errorf("unknown response field: %q", name)
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+// newEncoder returns a JSON encoder for the response to r. Responses are
+// compact unless the client asks for indentation with ?pretty=true.
+func newEncoder(w http.ResponseWriter, r *http.Request) *json.Encoder {
+	encoder := json.NewEncoder(w)
+	if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder
+}
+
+// writeResponse encodes response as JSON, dropping the optional sections not in fields
+func writeResponse(w http.ResponseWriter, r *http.Request, response interface{}, fields responseFields) {
+	writeStatusResponse(w, r, http.StatusOK, response, fields)
+}
+
+// writeStatusResponse is writeResponse with the given HTTP status
+func writeStatusResponse(w http.ResponseWriter, r *http.Request, status int, response interface{}, fields responseFields) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := newEncoder(w, r)
+	if fields == nil {
+		encoder.Encode(response)
+		return
+	}
+
+	// Round-trip through a generic map so any response type can be trimmed;
+	// UseNumber keeps large integers exact
+	data, err := json.Marshal(response)
+	var trimmed map[string]interface{}
+	if err == nil {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&trimmed)
+	}
+	if err != nil {
+		encoder.Encode(response)
+		return
+	}
+
+	for name, keys := range optionalSections {
+		if fields[name] {
+			continue
+		}
+		for _, key := range keys {
+			if caseKey := strings.TrimPrefix(key, "results."); caseKey != key {
+				results, _ := trimmed["results"].([]interface{})
+				for _, result := range results {
+					if obj, ok := result.(map[string]interface{}); ok {
+						delete(obj, caseKey)
+					}
+				}
+				continue
+			}
+			delete(trimmed, key)
+		}
+	}
+	encoder.Encode(trimmed)
+}
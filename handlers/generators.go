@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"online-compiler/models"
+	"strconv"
+	"sync"
+)
+
+// maxGeneratedInputs bounds the generated input cache; it is cleared when full
+const maxGeneratedInputs = 10000
+
+// GeneratorSpec describes a program that produces test inputs. The generator
+// is run in the sandbox once per seed, receiving the seed on stdin, and its
+// stdout becomes the input of one test case.
+type GeneratorSpec struct {
+	GeneratorCode     string `json:"generator_code,omitempty"`
+	GeneratorLanguage string `json:"generator_language,omitempty"`
+	SeedStart         int64  `json:"seed_start,omitempty"`
+	SeedCount         int    `json:"seed_count,omitempty"`
+}
+
+// validate checks the generator fields, if a generator was given
+func (g GeneratorSpec) validate() error {
+	if g.GeneratorCode == "" {
+		if g.GeneratorLanguage != "" || g.SeedCount != 0 || g.SeedStart != 0 {
+			return fmt.Errorf("generator_code is required when generator fields are set")
+		}
+		return nil
+	}
+	if g.GeneratorLanguage == "" {
+		return fmt.Errorf("generator_language is required with generator_code")
+	}
+	if err := validateRequest(models.ExecuteRequest{Code: g.GeneratorCode, Language: g.GeneratorLanguage}); err != nil {
+		return fmt.Errorf("generator: %w", err)
+	}
+	if g.SeedCount < 1 {
+		return fmt.Errorf("seed_count must be at least 1 with generator_code")
+	}
+	return nil
+}
+
+// generatedInputs caches generator output by generator and seed
+var generatedInputs = struct {
+	sync.Mutex
+	inputs map[string]string
+}{inputs: make(map[string]string)}
+
+// generatedInputKey identifies the input a generator produces for seed
+func generatedInputKey(language, code string, seed int64) string {
+	h := sha256.Sum256([]byte(strconv.Itoa(len(language)) + ":" + language + code))
+	return hex.EncodeToString(h[:]) + ":" + strconv.FormatInt(seed, 10)
+}
+
+// generateCases runs the generator for every seed not already cached and
+// returns one test case per seed. Generated cases have no expected output;
+// they pass as long as the solution runs without a failure verdict.
+func generateCases(ctx context.Context, g GeneratorSpec) ([]TestCase, error) {
+	cases := make([]TestCase, g.SeedCount)
+	var pending []int
+
+	generatedInputs.Lock()
+	for i := range cases {
+		seed := g.SeedStart + int64(i)
+		cases[i] = TestCase{ID: fmt.Sprintf("seed_%d", seed), generated: true}
+		input, ok := generatedInputs.inputs[generatedInputKey(g.GeneratorLanguage, g.GeneratorCode, seed)]
+		if ok {
+			cases[i].Input = input
+		} else {
+			pending = append(pending, i)
+		}
+	}
+	generatedInputs.Unlock()
+
+	if len(pending) == 0 {
+		return cases, nil
+	}
+
+	// Run the generator once per missing seed in a single batch
+	batchReq := models.BatchExecuteRequest{
+		Code:      g.GeneratorCode,
+		Language:  g.GeneratorLanguage,
+		TestCases: make([]models.TestInput, len(pending)),
+		NoStats:   true,
+	}
+	for j, i := range pending {
+		batchReq.TestCases[j] = models.TestInput{
+			ID:    fmt.Sprintf("gen_%d", j),
+			Input: strconv.FormatInt(g.SeedStart+int64(i), 10) + "\n",
+		}
+	}
+	outputs, _, err := executor.ExecuteBatch(ctx, batchReq)
+	if err != nil {
+		return nil, fmt.Errorf("generator failed: %w", err)
+	}
+
+	generatedInputs.Lock()
+	defer generatedInputs.Unlock()
+	if len(generatedInputs.inputs)+len(pending) > maxGeneratedInputs {
+		generatedInputs.inputs = make(map[string]string)
+	}
+	for j, i := range pending {
+		seed := g.SeedStart + int64(i)
+		if j >= len(outputs) {
+			return nil, fmt.Errorf("generator produced no output for seed %d", seed)
+		}
+		output := outputs[j].Output
+		if verdict := outputs[j].Verdict; verdict != "" {
+			return nil, fmt.Errorf("generator failed for seed %d (%s): %s", seed, verdict, output)
+		}
+		if err := checkControlChars(fmt.Sprintf("generated input for seed %d", seed), output); err != nil {
+			return nil, err
+		}
+		cases[i].Input = output
+		generatedInputs.inputs[generatedInputKey(g.GeneratorLanguage, g.GeneratorCode, seed)] = output
+	}
+	return cases, nil
+}
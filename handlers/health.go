@@ -0,0 +1,12 @@
+package handlers
+
+import "net/http"
+
+// HealthHandler answers liveness probes with 200 OK
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	// HEAD responses must not carry a body
+	if r.Method != http.MethodHead {
+		w.Write([]byte("OK"))
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// inputBlob is an uploaded stdin payload that executions can reference by ID
+type inputBlob struct {
+	data      string
+	expiresAt time.Time
+}
+
+// inputStore holds uploaded inputs until they expire
+type inputStore struct {
+	mu    sync.Mutex
+	blobs map[string]inputBlob
+}
+
+// inputs holds blobs uploaded through UploadInputHandler
+var inputs = &inputStore{blobs: make(map[string]inputBlob)}
+
+// put stores data and returns its reference
+func (s *inputStore) put(data string, ttl time.Duration) (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate input reference: %w", err)
+	}
+	ref := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictExpired()
+	s.blobs[ref] = inputBlob{data: data, expiresAt: expiresAt}
+	return ref, expiresAt, nil
+}
+
+// get returns the data for ref if it exists and has not expired
+func (s *inputStore) get(ref string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob, ok := s.blobs[ref]
+	if !ok || time.Now().After(blob.expiresAt) {
+		return "", false
+	}
+	return blob.data, true
+}
+
+// evictExpired removes expired blobs; the caller must hold s.mu
+func (s *inputStore) evictExpired() {
+	now := time.Now()
+	for ref, blob := range s.blobs {
+		if now.After(blob.expiresAt) {
+			delete(s.blobs, ref)
+		}
+	}
+}
+
+// UploadInputResponse is returned after an input is uploaded
+type UploadInputResponse struct {
+	InputRef  string `json:"input_ref"`
+	Size      int    `json:"size"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// UploadInputHandler stores the raw request body as a reusable stdin payload
+func UploadInputHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(config.MaxInputBlobSize)+1))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > config.MaxInputBlobSize {
+		http.Error(w, fmt.Sprintf("Input exceeds maximum size of %d bytes", config.MaxInputBlobSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ref, expiresAt, err := inputs.put(string(body), config.InputBlobTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(UploadInputResponse{
+		InputRef:  ref,
+		Size:      len(body),
+		ExpiresAt: expiresAt.Unix(),
+	})
+}
+
+// resolveInputRef replaces an input reference with the uploaded input it points to
+func resolveInputRef(input, ref string) (string, error) {
+	if ref == "" {
+		return input, nil
+	}
+	if input != "" {
+		return "", fmt.Errorf("input and input_ref cannot both be set")
+	}
+	data, ok := inputs.get(ref)
+	if !ok {
+		return "", fmt.Errorf("unknown or expired input_ref: %s", ref)
+	}
+	return data, nil
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"online-compiler/runner"
+
+	"github.com/gorilla/mux"
+)
+
+// errLargeBatchesBusy fails a queued job that would exceed the large batch limit when it starts
+var errLargeBatchesBusy = errors.New("too many large submissions in flight")
+
+// CreateJobHandler queues a submission to be graded in the background and
+// returns its job ID immediately; the result is fetched from GetJobHandler
+func CreateJobHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeSubmitRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id := requestID(r)
+	job, err := runner.SubmitJob(submitDeadline(req), func(ctx context.Context) (interface{}, error) {
+		release, ok := admitBatch(len(req.TestCases) + req.SeedCount)
+		if !ok {
+			return nil, errLargeBatchesBusy
+		}
+		defer release()
+		return runSubmission(ctx, req, id)
+	})
+	if errors.Is(err, runner.ErrServerBusy) {
+		sendServerBusy(w)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	log.Printf("[INFO] Queued job %s for %s submission with %d test cases", job.ID, req.Language, len(req.TestCases))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	newEncoder(w, r).Encode(job)
+}
+
+// GetJobHandler reports a job's status, with its result once it has finished
+func GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := runner.GetJob(mux.Vars(r)["id"])
+	if errors.Is(err, runner.ErrJobNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	newEncoder(w, r).Encode(job)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"online-compiler/runner"
+)
+
+// LanguageInfo describes a supported language and whether it is accepting executions
+type LanguageInfo struct {
+	Name             string `json:"name"`
+	Enabled          bool   `json:"enabled"`
+	ExecuteTimeoutMs int64  `json:"execute_timeout_ms"`         // Server-side limit for /execute
+	SubmitTimeoutMs  int64  `json:"submit_timeout_ms"`          // Server-side limit for /submit and /regrade
+	CompileTimeoutMs int64  `json:"compile_timeout_ms"`         // Compile phase limit, 0 for interpreted languages
+	DefaultCaseMs    int64  `json:"default_case_time_limit_ms"` // Per-case limit when a test case sets none
+	// Versions that can be requested, mapped to the image each runs on
+	Versions map[string]string `json:"versions,omitempty"`
+}
+
+// LanguagesHandler lists the supported languages and which of them are enabled
+func LanguagesHandler(w http.ResponseWriter, r *http.Request) {
+	var list []LanguageInfo
+	for _, name := range runner.SupportedLanguages() {
+		list = append(list, LanguageInfo{
+			Name:             name,
+			Enabled:          runner.LanguageEnabled(name),
+			ExecuteTimeoutMs: runner.ScaleTimeLimit(name, executeTimeout).Milliseconds(),
+			SubmitTimeoutMs:  runner.ScaleTimeLimit(name, submitTimeout).Milliseconds(),
+			CompileTimeoutMs: runner.CompileTimeout(name).Milliseconds(),
+			DefaultCaseMs:    runner.CaseTimeLimit(name, 0).Milliseconds(),
+			Versions:         runner.LanguageVersions(name),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxNormalizeSteps bounds the length of a normalization pipeline
+const maxNormalizeSteps = 10
+
+// transform is one step of a normalization pipeline
+type transform func(string) string
+
+// transforms are the named steps a normalization pipeline can be built from
+var transforms = map[string]transform{
+	"trim":        trimLines,
+	"sort_lines":  sortLines,
+	"collapse_ws": collapseWhitespace,
+	"lowercase":   strings.ToLower,
+}
+
+// validatePipeline checks that every step of a normalization pipeline exists
+func validatePipeline(steps []string) error {
+	if len(steps) > maxNormalizeSteps {
+		return fmt.Errorf("normalize may have at most %d steps", maxNormalizeSteps)
+	}
+	for _, step := range steps {
+		if _, ok := transforms[step]; !ok {
+			return fmt.Errorf("unsupported normalize step: %s", step)
+		}
+	}
+	return nil
+}
+
+// applyPipeline runs output through the named transforms in order
+func applyPipeline(output string, steps []string) string {
+	for _, step := range steps {
+		output = transforms[step](output)
+	}
+	return output
+}
+
+// trimLines strips leading and trailing whitespace from every line
+func trimLines(output string) string {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sortLines sorts the lines of output, for answers whose order doesn't matter.
+// Trailing newlines are dropped first so they don't sort as empty lines.
+func sortLines(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\r\n"), "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// collapseWhitespace replaces each run of spaces and tabs within a line with a single space
+func collapseWhitespace(output string) string {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import "testing"
+
+func TestApplyPipeline(t *testing.T) {
+	tests := []struct {
+		name   string
+		steps  []string
+		output string
+		want   string
+	}{
+		{"no steps", nil, " B\n a ", " B\n a "},
+		{"trim", []string{"trim"}, "  a \n\tb\t", "a\nb"},
+		{"collapse_ws also trims", []string{"collapse_ws"}, "  a \t b  ", "a b"},
+		{"trim then collapse_ws", []string{"trim", "collapse_ws"}, " Hello   World \n FOO\tbar ", "Hello World\nFOO bar"},
+		{"trim, collapse_ws and lowercase", []string{"trim", "collapse_ws", "lowercase"}, " Hello   World \n FOO\tbar ", "hello world\nfoo bar"},
+		{"lowercase first gives the same result", []string{"lowercase", "collapse_ws", "trim"}, " Hello   World \n FOO\tbar ", "hello world\nfoo bar"},
+
+		// Steps run in the order given, which matters once lines are sorted
+		{"sort_lines then lowercase", []string{"sort_lines", "lowercase"}, "B\na", "b\na"},
+		{"lowercase then sort_lines", []string{"lowercase", "sort_lines"}, "B\na", "a\nb"},
+		{"sort_lines then trim", []string{"sort_lines", "trim"}, " b\na", "b\na"},
+		{"trim then sort_lines", []string{"trim", "sort_lines"}, " b\na", "a\nb"},
+		{"sort_lines then collapse_ws", []string{"sort_lines", "collapse_ws"}, "a  c\na b", "a c\na b"},
+		{"collapse_ws then sort_lines", []string{"collapse_ws", "sort_lines"}, "a  c\na b", "a b\na c"},
+		{"every step", []string{"trim", "collapse_ws", "lowercase", "sort_lines"}, " B  x\n\ta   Y \n", "a y\nb x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePipeline(tt.steps); err != nil {
+				t.Fatalf("pipeline %v is invalid: %v", tt.steps, err)
+			}
+			if got := applyPipeline(tt.output, tt.steps); got != tt.want {
+				t.Errorf("applyPipeline(%q, %v) = %q, want %q", tt.output, tt.steps, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareNormalizeWithIgnoreCase(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     CompareOptions
+		expected string
+		actual   string
+		want     bool
+	}{
+		{"trim and collapse_ws with ignore_case", CompareOptions{IgnoreCase: true, Normalize: []string{"trim", "collapse_ws"}}, "hello world\nfoo bar", " Hello   World \n FOO\tbar ", true},
+		{"ignore_case alone keeps inner spacing", CompareOptions{IgnoreCase: true}, "hello world", "Hello   World", false},
+		// ignore_case folds after the pipeline, so lines sort by their original case
+		{"ignore_case folds after sort_lines", CompareOptions{IgnoreCase: true, Normalize: []string{"sort_lines"}}, "a\nb", "B\na", false},
+		{"lowercase before sort_lines", CompareOptions{Normalize: []string{"lowercase", "sort_lines"}}, "a\nb", "B\na", true},
+		{"similarity over normalized lines", CompareOptions{Mode: CompareSimilar, SimilarityThreshold: 100, IgnoreCase: true, Normalize: []string{"collapse_ws"}}, "a b\nc d", "A   B\nc\td", true},
+		{"whitespace mode with sort_lines", CompareOptions{Mode: CompareWhitespace, IgnoreCase: true, Normalize: []string{"trim", "sort_lines"}}, "1 X\n2 y", " 2  Y\n1 x ", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(); err != nil {
+				t.Fatalf("options %+v are invalid: %v", tt.opts, err)
+			}
+			if got := compareOutputs(tt.expected, tt.actual, tt.opts).Passed; got != tt.want {
+				t.Errorf("compareOutputs(%q, %q, %+v) passed = %v, want %v", tt.expected, tt.actual, tt.opts, got, tt.want)
+			}
+		})
+	}
+}
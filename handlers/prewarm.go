@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"online-compiler/models"
+	"online-compiler/runner"
+	"time"
+)
+
+// PrewarmRequest asks for code to be compiled into the compile cache without running it
+type PrewarmRequest struct {
+	Code     string `json:"code"`
+	Language string `json:"language"`
+}
+
+// PrewarmResponse reports the compile cache entry for the prewarmed code
+type PrewarmResponse struct {
+	Status    string `json:"status"`
+	CacheKey  string `json:"cache_key"`
+	Cached    bool   `json:"cached"` // The code was already in the cache
+	Timestamp int64  `json:"timestamp"`
+}
+
+// PrewarmHandler compiles code and stores the build artifacts so later
+// submissions of the same code skip compilation. Repeating it is harmless.
+func PrewarmHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), executeTimeout)
+	defer cancel()
+	setTimeoutHeader(w, executeTimeout)
+
+	var req PrewarmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" || req.Language == "" {
+		http.Error(w, "Code and language are required", http.StatusBadRequest)
+		return
+	}
+	if err := validateRequest(models.ExecuteRequest{Code: req.Code, Language: req.Language}); err != nil {
+		sendRequestError(w, err)
+		return
+	}
+
+	key, cached, err := runner.Prewarm(ctx, req.Language, req.Code)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, runner.ErrWarmingUp):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, runner.ErrNotCompiled):
+			status = http.StatusBadRequest
+		case errors.Is(err, runner.ErrCompileFailed):
+			status = http.StatusUnprocessableEntity
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PrewarmResponse{
+		Status:    "success",
+		CacheKey:  key,
+		Cached:    cached,
+		Timestamp: time.Now().Unix(),
+	})
+}
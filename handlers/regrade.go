@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"online-compiler/runner"
+	"time"
+)
+
+// RegradeRequest re-grades a submission, running only the listed test cases
+// and retaining the prior results of every other case
+type RegradeRequest struct {
+	SubmitRequest
+	PriorResults []TestCaseResult `json:"prior_results"`
+	Rerun        []string         `json:"rerun"` // IDs of new or changed test cases
+}
+
+// RegradeResponse is a SubmitResponse that also reports how many cases were re-run
+type RegradeResponse struct {
+	SubmitResponse
+	RerunCases int `json:"rerun_cases"`
+}
+
+func RegradeHandler(w http.ResponseWriter, r *http.Request) {
+	fields, err := parseFields(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req RegradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if err := validateSubmitRequest(req.SubmitRequest); err != nil {
+		sendRequestError(w, err)
+		return
+	}
+
+	// Every case needs a unique ID to be matched against prior results
+	seen := make(map[string]bool, len(req.TestCases))
+	for i, tc := range req.TestCases {
+		if tc.ID == "" {
+			http.Error(w, fmt.Sprintf("test_cases[%d]: id is required for regrading", i), http.StatusBadRequest)
+			return
+		}
+		if seen[tc.ID] {
+			http.Error(w, fmt.Sprintf("test_cases[%d]: duplicate id %q", i, tc.ID), http.StatusBadRequest)
+			return
+		}
+		seen[tc.ID] = true
+	}
+
+	prior := make(map[string]TestCaseResult, len(req.PriorResults))
+	for _, result := range req.PriorResults {
+		prior[result.ID] = result
+	}
+	rerun := make(map[string]bool, len(req.Rerun))
+	for _, id := range req.Rerun {
+		rerun[id] = true
+	}
+
+	// Run the requested cases plus any case without a prior result
+	subset := req.SubmitRequest
+	subset.TestCases = nil
+	for _, tc := range req.TestCases {
+		if _, ok := prior[tc.ID]; rerun[tc.ID] || !ok {
+			subset.TestCases = append(subset.TestCases, tc)
+		}
+	}
+
+	// Set timeout context from the cases that are re-run
+	timeout := submitDeadline(subset)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	setTimeoutHeader(w, timeout)
+
+	if !runner.ImageReady() {
+		http.Error(w, runner.ErrWarmingUp.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// Keep large regrades from starving interactive executions
+	release, ok := admitBatch(len(subset.TestCases))
+	if !ok {
+		sendBatchRejected(w)
+		return
+	}
+	defer release()
+
+	// Start timing
+	startTime := time.Now()
+
+	var outcome gradeOutcome
+	if len(subset.TestCases) > 0 {
+		outcome = gradeCases(ctx, subset)
+	}
+	if errors.Is(outcome.Err, runner.ErrServerBusy) {
+		sendServerBusy(w)
+		return
+	}
+
+	// Calculate execution time
+	totalTime := time.Since(startTime)
+
+	response := RegradeResponse{
+		SubmitResponse: SubmitResponse{
+			Status:        outcome.status(),
+			Error:         outcome.errorMessage(),
+			CompileError:  outcome.CompileError,
+			TotalCases:    len(req.TestCases),
+			ExecutionTime: milliseconds(totalTime),
+			Timing:        newSubmitTiming(totalTime, outcome.Metrics),
+			MemoryPeak:    outcome.Metrics.Memory.MemoryPeakBytes,
+			BinarySize:    outcome.Metrics.BinarySize,
+			Cache:         cacheStatus(req.NoCache),
+			Image:         outcome.Metrics.Image,
+			StackSizeMB:   runner.StackLimit(req.StackSizeMB),
+			Timestamp:     time.Now().Unix(),
+			RequestID:     requestID(r),
+		},
+		RerunCases: len(subset.TestCases),
+	}
+
+	// Merge fresh results with retained ones in test case order
+	if outcome.CompileError == "" {
+		fresh := make(map[string]TestCaseResult, len(outcome.Results))
+		for _, result := range outcome.Results {
+			fresh[result.ID] = result
+		}
+		for _, tc := range req.TestCases {
+			result, ok := fresh[tc.ID]
+			if !ok {
+				result = prior[tc.ID]
+			}
+			if result.Passed {
+				response.PassedCases++
+			}
+			response.Results = append(response.Results, result)
+		}
+	}
+	response.Verdict = gradeOutcome{Results: response.Results, CompileError: outcome.CompileError}.verdict()
+
+	// Send response
+	writeResponse(w, r, response, fields)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"online-compiler/middleware"
+	"online-compiler/runner"
+
+	"github.com/gorilla/mux"
+)
+
+// StatsHandler reports the health of the execution stats pipeline
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runner.GetStatsSummary())
+}
+
+// ActiveExecutionsHandler lists the executions currently running, so a stuck
+// one can be found and its container killed
+func ActiveExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runner.ActiveExecutions())
+}
+
+// KillExecutionHandler cancels a running execution, killing its container,
+// as an escape hatch for a submission that wedges the host despite limits
+func KillExecutionHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	exec, err := runner.KillExecution(id)
+	if errors.Is(err, runner.ErrExecutionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("[AUDIT] Execution %s (%s, container %s) killed by admin request from %s after %d ms",
+		exec.ID, exec.Language, exec.Container, r.RemoteAddr, exec.ElapsedMs)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exec)
+}
+
+// PrometheusHandler serves execution and worker pool metrics in the Prometheus text format
+func PrometheusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	runner.WritePrometheusMetrics(w)
+}
+
+// QueueMetricsResponse is the worker pool load plus the execution requests the server is handling
+type QueueMetricsResponse struct {
+	runner.QueueMetrics
+	InFlightRequests int64 `json:"in_flight_requests"` // Execution requests admitted by the concurrency limiter
+}
+
+// QueueMetricsHandler reports queue depth and worker utilization for autoscalers
+func QueueMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(QueueMetricsResponse{
+		QueueMetrics:     runner.GetQueueMetrics(),
+		InFlightRequests: middleware.InFlightExecutions(),
+	})
+}
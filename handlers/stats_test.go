@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(PrometheusHandler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("scrape returned %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	for _, want := range []string{
+		"# TYPE compiler_executions_total counter",
+		"# TYPE compiler_execution_duration_seconds histogram",
+		"# TYPE compiler_queue_depth gauge",
+		"# TYPE compiler_busy_workers gauge",
+		"compiler_queue_depth ",
+		"compiler_workers ",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("scrape has no %q:\n%s", want, body)
+		}
+	}
+}
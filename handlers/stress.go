@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"online-compiler/models"
+	"online-compiler/runner"
+	"sync"
+	"time"
+)
+
+// stressChunkSize is how many seeds are generated and run per round
+const stressChunkSize = 10
+
+// StressRequest stress-tests a solution against a reference on generated inputs
+type StressRequest struct {
+	Code              string `json:"code"`
+	Language          string `json:"language"`
+	ReferenceCode     string `json:"reference_code"`
+	ReferenceLanguage string `json:"reference_language"`
+	GeneratorCode     string `json:"generator_code"`
+	GeneratorLanguage string `json:"generator_language"`
+	SeedStart         int64  `json:"seed_start,omitempty"`
+	Iterations        int    `json:"iterations"`
+	CompareOptions
+}
+
+// StressResponse reports the first input on which the solution and reference disagree
+type StressResponse struct {
+	Status          string         `json:"status"` // "mismatch", "passed" or "time_limit"
+	Iterations      int            `json:"iterations"`
+	Seed            *int64         `json:"seed,omitempty"`
+	Input           string         `json:"input,omitempty"`
+	Output          string         `json:"output,omitempty"`
+	ReferenceOutput string         `json:"reference_output,omitempty"`
+	Verdict         models.Verdict `json:"verdict,omitempty"` // Failure verdict of the solution, if it did not run cleanly
+	FirstMismatch   *Mismatch      `json:"first_mismatch,omitempty"`
+	Timestamp       int64          `json:"timestamp"`
+	RequestID       string         `json:"request_id,omitempty"`
+}
+
+// validate checks a stress request against the configured limits
+func (req StressRequest) validate() error {
+	programs := []struct{ name, code, language string }{
+		{"solution", req.Code, req.Language},
+		{"reference", req.ReferenceCode, req.ReferenceLanguage},
+		{"generator", req.GeneratorCode, req.GeneratorLanguage},
+	}
+	for _, p := range programs {
+		if p.code == "" || p.language == "" {
+			return fmt.Errorf("%s code and language are required", p.name)
+		}
+		if err := validateRequest(models.ExecuteRequest{Code: p.code, Language: p.language}); err != nil {
+			return fmt.Errorf("%s: %w", p.name, err)
+		}
+	}
+	if req.Iterations < 1 || req.Iterations > config.MaxStressIterations {
+		return fmt.Errorf("iterations must be between 1 and %d", config.MaxStressIterations)
+	}
+	// The reference output is plain text, never a pattern
+	if req.Mode == CompareRegex {
+		return fmt.Errorf("comparison_mode %q is not supported for stress testing", CompareRegex)
+	}
+	return req.CompareOptions.validate()
+}
+
+// StressHandler runs the solution and the reference on generated inputs until
+// their outputs differ, the iterations run out, or the time budget is spent
+func StressHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), config.StressTimeout)
+	defer cancel()
+	setTimeoutHeader(w, config.StressTimeout)
+
+	var req StressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		sendRequestError(w, err)
+		return
+	}
+
+	response := StressResponse{Status: "passed", RequestID: requestID(r)}
+	for response.Iterations < req.Iterations {
+		// Leave the remaining budget unused rather than start a round that cannot finish
+		if ctx.Err() != nil {
+			response.Status = "time_limit"
+			break
+		}
+
+		count := req.Iterations - response.Iterations
+		if count > stressChunkSize {
+			count = stressChunkSize
+		}
+		found, err := stressRound(ctx, req, req.SeedStart+int64(response.Iterations), count, &response)
+		if errors.Is(err, runner.ErrServerBusy) && response.Iterations == 0 {
+			sendServerBusy(w)
+			return
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				response.Status = "time_limit"
+				break
+			}
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if found {
+			response.Status = "mismatch"
+			break
+		}
+	}
+
+	response.Timestamp = time.Now().Unix()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// stressRound generates count inputs starting at seed, runs both programs on
+// them and records the first disagreement in response, reporting whether one was found
+func stressRound(ctx context.Context, req StressRequest, seed int64, count int, response *StressResponse) (bool, error) {
+	cases, err := generateCases(ctx, GeneratorSpec{
+		GeneratorCode:     req.GeneratorCode,
+		GeneratorLanguage: req.GeneratorLanguage,
+		SeedStart:         seed,
+		SeedCount:         count,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// Run the solution and the reference side by side
+	var solution, reference []runner.BatchResult
+	var solutionErr, referenceErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		solution, solutionErr = runOnCases(ctx, req.Code, req.Language, cases)
+	}()
+	go func() {
+		defer wg.Done()
+		reference, referenceErr = runOnCases(ctx, req.ReferenceCode, req.ReferenceLanguage, cases)
+	}()
+	wg.Wait()
+	if solutionErr != nil {
+		return false, fmt.Errorf("solution failed: %w", solutionErr)
+	}
+	if referenceErr != nil {
+		return false, fmt.Errorf("reference failed: %w", referenceErr)
+	}
+
+	if len(solution) != len(cases) || len(reference) != len(cases) {
+		return false, fmt.Errorf("batch returned %d solution and %d reference results for %d cases",
+			len(solution), len(reference), len(cases))
+	}
+
+	for i, tc := range cases {
+		expected := reference[i].Output
+		if verdict := reference[i].Verdict; verdict != "" {
+			return false, fmt.Errorf("reference failed for seed %d (%s): %s", seed+int64(i), verdict, expected)
+		}
+
+		response.Iterations++
+		actual := solution[i].Output
+		verdict := solution[i].Verdict
+		outcome := compareOutputs(expected, actual, req.CompareOptions)
+		if verdict == "" && outcome.Passed {
+			continue
+		}
+
+		caseSeed := seed + int64(i)
+		response.Seed = &caseSeed
+		response.Input = tc.Input
+		response.Output = actual
+		response.ReferenceOutput = expected
+		response.Verdict = verdict
+		response.FirstMismatch = outcome.Mismatch
+		return true, nil
+	}
+	return false, nil
+}
+
+// runOnCases runs code against the inputs of cases in a single batch, returning results in case order
+func runOnCases(ctx context.Context, code, language string, cases []TestCase) ([]runner.BatchResult, error) {
+	batchReq := models.BatchExecuteRequest{
+		Code:      code,
+		Language:  language,
+		TestCases: make([]models.TestInput, len(cases)),
+		NoStats:   true,
+	}
+	for i, tc := range cases {
+		batchReq.TestCases[i] = models.TestInput{ID: fmt.Sprintf("tc_%d", i), Input: tc.Input}
+	}
+	results, _, err := executor.ExecuteBatch(ctx, batchReq)
+	return results, err
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// FieldError describes a problem with a single request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is returned when a request body fails structural validation
+type ValidationErrorResponse struct {
+	Status    string       `json:"status"`
+	Error     string       `json:"error"`
+	Fields    []FieldError `json:"fields"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+// JSON value kinds used in field checks
+const (
+	kindString  = "a string"
+	kindNumber  = "a number"
+	kindInteger = "an integer"
+	kindBool    = "a boolean"
+	kindObject  = "an object"
+	kindArray   = "an array"
+	kindNull    = "null"
+)
+
+// fieldSpec describes the expected kind of a field and whether it must be present
+type fieldSpec struct {
+	kind     string
+	required bool
+}
+
+// submitFields lists the top-level fields accepted by SubmitHandler
+var submitFields = map[string]fieldSpec{
+	"code":                 {kindString, false}, // Required unless an artifact is given
+	"artifact":             {kindObject, false},
+	"files":                {kindArray, false},
+	"language":             {kindString, true},
+	"input":                {kindString, false},
+	"env":                  {kindObject, false},
+	"comparison_mode":      {kindString, false},
+	"ignore_case":          {kindBool, false},
+	"similarity_threshold": {kindNumber, false},
+	"epsilon":              {kindNumber, false},
+	"normalize":            {kindArray, false},
+	"no_stats":             {kindBool, false},
+	"no_cache":             {kindBool, false},
+	"version":              {kindString, false},
+	"stack_size_mb":        {kindInteger, false},
+	"shards":               {kindInteger, false},
+	"hash_outputs":         {kindBool, false},
+	"generator_code":       {kindString, false},
+	"generator_language":   {kindString, false},
+	"seed_start":           {kindInteger, false},
+	"seed_count":           {kindInteger, false},
+	"test_cases":           {kindArray, true},
+}
+
+// sourceFileFields lists the fields accepted on each file of a multi-file program
+var sourceFileFields = map[string]fieldSpec{
+	"path":    {kindString, true},
+	"content": {kindString, false},
+}
+
+// testCaseFields lists the fields accepted on each submitted test case
+var testCaseFields = map[string]fieldSpec{
+	"id":              {kindString, false},
+	"input":           {kindString, false},
+	"expected_output": {kindString, false},
+	"time_limit_ms":   {kindInteger, false},
+	"memory_limit_mb": {kindInteger, false},
+}
+
+// validateSubmitPayload checks the shape of a raw submit request and returns one error per bad field
+func validateSubmitPayload(body []byte) []FieldError {
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(body, &root); err != nil {
+		return []FieldError{{Field: "", Message: "request body must be a JSON object"}}
+	}
+
+	errs := checkObject("", root, submitFields)
+
+	if raw, ok := root["env"]; ok && jsonKind(raw) == kindObject {
+		var env map[string]json.RawMessage
+		json.Unmarshal(raw, &env)
+		for key, value := range env {
+			if jsonKind(value) != kindString {
+				errs = append(errs, FieldError{Field: "env." + key, Message: "env." + key + " must be a string"})
+			}
+		}
+	}
+
+	errs = append(errs, checkArray(root, "files", sourceFileFields)...)
+	errs = append(errs, checkArray(root, "test_cases", testCaseFields)...)
+	return errs
+}
+
+// checkArray validates each object of the array field name in root against specs
+func checkArray(root map[string]json.RawMessage, name string, specs map[string]fieldSpec) []FieldError {
+	raw, ok := root[name]
+	if !ok || jsonKind(raw) != kindArray {
+		return nil
+	}
+	var errs []FieldError
+	var items []json.RawMessage
+	json.Unmarshal(raw, &items)
+	for i, item := range items {
+		field := fmt.Sprintf("%s[%d]", name, i)
+		var obj map[string]json.RawMessage
+		if jsonKind(item) != kindObject || json.Unmarshal(item, &obj) != nil {
+			errs = append(errs, FieldError{Field: field, Message: field + " must be an object"})
+			continue
+		}
+		errs = append(errs, checkObject(field+".", obj, specs)...)
+	}
+	return errs
+}
+
+// checkObject validates the fields of obj against specs, prefixing field names with prefix
+func checkObject(prefix string, obj map[string]json.RawMessage, specs map[string]fieldSpec) []FieldError {
+	var errs []FieldError
+	for _, name := range sortedKeys(specs) {
+		spec := specs[name]
+		field := prefix + name
+		raw, ok := obj[name]
+		if !ok || jsonKind(raw) == kindNull {
+			if spec.required {
+				errs = append(errs, FieldError{Field: field, Message: field + " is required"})
+			}
+			continue
+		}
+		if !matchesKind(raw, spec.kind) {
+			errs = append(errs, FieldError{Field: field, Message: field + " must be " + spec.kind})
+		}
+	}
+	return errs
+}
+
+// jsonKind returns the kind of a raw JSON value
+func jsonKind(raw json.RawMessage) string {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return ""
+	}
+	switch trimmed[0] {
+	case '"':
+		return kindString
+	case '{':
+		return kindObject
+	case '[':
+		return kindArray
+	case 't', 'f':
+		return kindBool
+	case 'n':
+		return kindNull
+	default:
+		return kindNumber
+	}
+}
+
+// matchesKind reports whether raw holds a value of the given kind
+func matchesKind(raw json.RawMessage, kind string) bool {
+	if kind == kindInteger {
+		var n int
+		return jsonKind(raw) == kindNumber && json.Unmarshal(raw, &n) == nil
+	}
+	return jsonKind(raw) == kind
+}
+
+// sortedKeys returns the keys of specs in a stable order so errors are reported deterministically
+func sortedKeys(specs map[string]fieldSpec) []string {
+	keys := make([]string, 0, len(specs))
+	for key := range specs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sendValidationErrors writes a 400 response listing every field error
+func sendValidationErrors(w http.ResponseWriter, errs []FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Status:    "error",
+		Error:     "Invalid request body",
+		Fields:    errs,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// checkControlChars rejects null bytes and control characters other than
+// ordinary whitespace (tab, newline, vertical tab, form feed, carriage return)
+func checkControlChars(field, value string) error {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c < 0x20 && c != '\t' && c != '\n' && c != '\v' && c != '\f' && c != '\r') || c == 0x7f {
+			return fmt.Errorf("%s contains a disallowed control character (0x%02x) at byte %d", field, c, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"online-compiler/models"
+	"strings"
+	"testing"
+)
+
+func TestCheckControlChars(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"plain text", "print(1)", false},
+		{"ordinary whitespace", "a\tb\nc\r\n\v\f", false},
+		{"utf-8", "héllo, 世界", false},
+		{"null byte", "print(1)\x00", true},
+		{"escape", "\x1b[2J", true},
+		{"bell", "\a", true},
+		{"delete", "abc\x7f", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkControlChars("code", tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("checkControlChars(%q) = %v, want error: %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRequestRejectsNullBytes(t *testing.T) {
+	requests := map[string]models.ExecuteRequest{
+		"code":  {Language: "python", Code: "print(1)\x00"},
+		"input": {Language: "python", Code: "print(input())", Input: "1\x002"},
+		"helper.py": {Language: "python", Code: "import helper", Files: []models.SourceFile{
+			{Path: "helper.py", Content: "x = 1\x00"},
+		}},
+	}
+
+	for field, req := range requests {
+		err := validateRequest(req)
+		if err == nil || !strings.Contains(err.Error(), field+" contains a disallowed control character (0x00)") {
+			t.Errorf("validateRequest with a null byte in %s = %v, want it rejected", field, err)
+		}
+	}
+}
+
+func TestValidateSubmitRequestRejectsNullBytes(t *testing.T) {
+	req := SubmitRequest{TestCases: []TestCase{{Input: "1", ExpectedOutput: "1"}, {Input: "2\x00", ExpectedOutput: "2"}}}
+	req.Language, req.Code = "python", "print(input())"
+
+	err := validateSubmitRequest(req)
+	if err == nil || !strings.Contains(err.Error(), "test_cases[1].input contains a disallowed control character (0x00)") {
+		t.Errorf("validateSubmitRequest() = %v, want test_cases[1].input rejected", err)
+	}
+}
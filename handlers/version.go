@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"online-compiler/version"
+)
+
+// buildInfo is reported by VersionHandler
+var buildInfo = version.Get()
+
+// SetImageInfo records the sandbox image tag and digest discovered at startup
+func SetImageInfo(image, digest string) {
+	buildInfo.Image = image
+	buildInfo.ImageDigest = digest
+}
+
+// VersionHandler reports which build is running
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildInfo)
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"online-compiler/handlers"
 	"online-compiler/middleware"
 	"online-compiler/models"
+	"online-compiler/runner"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -14,37 +19,147 @@ import (
 func main() {
 	// Load configuration
 	config := models.LoadConfig()
+	runner.Configure(config)
+	handlers.Configure(config)
+
+	// Set up result storage
+	store, err := runner.NewResultStore(config.ResultStore, config.ResultStoreDSN, config.MaxStoredResults)
+	if err != nil {
+		log.Fatalf("Failed to initialize result store: %v", err)
+	}
+	runner.SetResultStore(store)
+
+	// Load the optional static scan of submitted code
+	if err := runner.LoadBannedPatterns(config.BannedPatternsFile); err != nil {
+		log.Fatalf("Failed to load banned patterns: %v", err)
+	}
+
+	// Select and verify the sandbox backend before accepting traffic
+	sandbox, err := runner.NewSandbox(config)
+	if err != nil {
+		log.Fatalf("Startup check failed: %v", err)
+	}
+	runner.SetSandbox(sandbox)
+	handlers.SetExecutor(runner.DockerExecutor{})
+	if err := prepareSandbox(sandbox, config.StartupRetries, config.StartupRetryBackoff); err != nil {
+		log.Fatalf("Startup check failed: %v", err)
+	}
+	runner.StartWorkers()
+	if config.SandboxMode == "local" {
+		handlers.SetImageInfo("local", "")
+	} else {
+		digest, err := runner.ImageDigest()
+		if err != nil {
+			log.Printf("Could not determine image digest: %v", err)
+		}
+		handlers.SetImageInfo(config.Image, digest)
+	}
 
 	// Create router
 	r := mux.NewRouter()
 
+	// Only requests from these proxies may assert a tenant or user
+	trustedProxies, err := middleware.ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Add middleware
+	r.Use(middleware.IdentityMiddleware(trustedProxies, config.TenantHeader, config.UserHeader))
 	r.Use(middleware.LoggingMiddleware)
 	r.Use(middleware.RecoveryMiddleware)
 	r.Use(middleware.CORSMiddleware)
 	r.Use(middleware.RequestIDMiddleware)
 	r.Use(middleware.RateLimitMiddleware)
+	r.Use(middleware.ConcurrencyLimitMiddleware(config.BasePath, config.MaxConcurrentPerIP))
+	r.Use(middleware.MaintenanceMiddleware(config.BasePath, config.MaintenanceMode, config.MaintenanceRetryAfter, config.MaintenanceMessage))
 
-	// Add routes
-	r.HandleFunc("/execute", handlers.ExecuteHandler).Methods("POST")
-	r.HandleFunc("/submit", handlers.SubmitHandler).Methods("POST")
-	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	}).Methods("GET")
+	// Add routes, under BASE_PATH when one is configured
+	routes := r
+	if config.BasePath != "" {
+		routes = r.PathPrefix(config.BasePath).Subrouter()
+	}
+	routes.HandleFunc("/execute", handlers.ExecuteHandler).Methods("POST")
+	routes.HandleFunc("/execute/bulk", handlers.BulkExecuteHandler).Methods("POST")
+	routes.HandleFunc("/inputs", handlers.UploadInputHandler).Methods("POST")
+	routes.HandleFunc("/submit", handlers.SubmitHandler).Methods("POST")
+	routes.HandleFunc("/jobs", handlers.CreateJobHandler).Methods("POST")
+	routes.HandleFunc("/jobs/{id}", handlers.GetJobHandler).Methods("GET")
+	routes.HandleFunc("/regrade", handlers.RegradeHandler).Methods("POST")
+	routes.HandleFunc("/prewarm", handlers.PrewarmHandler).Methods("POST")
+	routes.HandleFunc("/stress", handlers.StressHandler).Methods("POST")
+	routes.HandleFunc("/languages", handlers.LanguagesHandler).Methods("GET")
+	routes.HandleFunc("/version", handlers.VersionHandler).Methods("GET")
+	routes.HandleFunc("/stats", handlers.StatsHandler).Methods("GET")
+	routes.HandleFunc("/metrics/queue", handlers.QueueMetricsHandler).Methods("GET")
+	routes.HandleFunc("/health", handlers.HealthHandler).Methods("GET", "HEAD")
+
+	// Operational endpoints, only reachable with ADMIN_TOKEN
+	admin := routes.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.AdminAuthMiddleware(config.AdminToken))
+	admin.HandleFunc("/executions", handlers.ActiveExecutionsHandler).Methods("GET")
+	admin.HandleFunc("/executions/{id}/kill", handlers.KillExecutionHandler).Methods("POST")
+
+	// Serve Prometheus scrapes ahead of the router so the rate limiter and
+	// maintenance mode never turn them away
+	root := http.NewServeMux()
+	root.HandleFunc("/metrics", handlers.PrometheusHandler)
+	root.Handle("/", r)
+
+	// Keep the write deadline past every handler's own timeout, with time
+	// left to write the response, so clients get the advertised cutoff
+	writeTimeout := config.WriteTimeout
+	if longest := handlers.MaxRequestTimeout() + 5*time.Second; longest > writeTimeout {
+		log.Printf("[INFO] Raising write timeout from %v to %v to outlast the longest request timeout", writeTimeout, longest)
+		writeTimeout = longest
+	}
 
 	// Create server with timeouts
 	srv := &http.Server{
-		Handler:      r,
+		Handler:      root,
 		Addr:         config.Port,
-		WriteTimeout: 30 * time.Second,
-		ReadTimeout:  30 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		WriteTimeout: writeTimeout,
+		ReadTimeout:  config.ReadTimeout,
+		IdleTimeout:  config.IdleTimeout,
 	}
 
 	// Start server
-	log.Printf("Server starting on %s", config.Port)
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	go func() {
+		log.Printf("Server starting on %s", config.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	// Wait for a shutdown signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Printf("Shutting down server")
+
+	// Stop accepting requests, then drain executions and stats
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown failed: %v", err)
+	}
+	if err := runner.Shutdown(ctx); err != nil {
+		log.Printf("Runner shutdown failed: %v", err)
+	}
+	log.Printf("Server stopped")
+}
+
+// prepareSandbox prepares the sandbox, retrying with exponential backoff so a
+// daemon that is still starting up doesn't fail the boot. The server does not
+// listen until it succeeds, so readiness checks fail in the meantime.
+func prepareSandbox(sandbox runner.Sandbox, retries int, backoff time.Duration) error {
+	for attempt := 0; ; attempt++ {
+		err := sandbox.Prepare()
+		if err == nil || attempt >= retries {
+			return err
+		}
+		log.Printf("[WARN] Sandbox not ready (attempt %d of %d), retrying in %v: %v", attempt+1, retries+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
 	}
 }
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Identity is the tenant and user an auth gateway attached to a request
+type Identity struct {
+	TenantID string
+	UserID   string
+}
+
+// identityKey is the request context key holding the Identity
+type identityKey struct{}
+
+// IdentityFrom returns the identity attached to ctx by IdentityMiddleware, if any
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// ParseTrustedProxies parses a list of IP addresses and CIDR ranges
+func ParseTrustedProxies(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range list {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", item)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// isTrusted reports whether the request came directly from a trusted proxy
+func isTrusted(r *http.Request, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(clientIP(r))
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IdentityMiddleware attaches the tenant and user named by tenantHeader and
+// userHeader to the request context, but only for requests arriving from a
+// trusted proxy. Elsewhere the headers are removed so a client cannot spoof
+// another tenant. Malformed values from a trusted proxy are rejected with 400.
+func IdentityMiddleware(trusted []*net.IPNet, tenantHeader, userHeader string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isTrusted(r, trusted) {
+				r.Header.Del(tenantHeader)
+				r.Header.Del(userHeader)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id := Identity{TenantID: r.Header.Get(tenantHeader), UserID: r.Header.Get(userHeader)}
+			for header, value := range map[string]string{tenantHeader: id.TenantID, userHeader: id.UserID} {
+				if value != "" && !correlationIDPattern.MatchString(value) {
+					http.Error(w, "Invalid "+header+" header", http.StatusBadRequest)
+					return
+				}
+			}
+			if id.TenantID == "" && id.UserID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+		})
+	}
+}
+
+// clientKey identifies the client for rate and concurrency limits: the
+// tenant when a trusted gateway named one, otherwise the client IP
+func clientKey(r *http.Request) string {
+	if id, ok := IdentityFrom(r.Context()); ok && id.TenantID != "" {
+		return "tenant:" + id.TenantID
+	}
+	return clientIP(r)
+}
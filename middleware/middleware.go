@@ -1,10 +1,17 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,7 +20,11 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		next.ServeHTTP(w, r)
-		log.Printf("[%s] %s %s %v", r.Method, r.URL.Path, r.RemoteAddr, time.Since(start))
+		identity := ""
+		if id, ok := IdentityFrom(r.Context()); ok {
+			identity = fmt.Sprintf(" tenant=%s user=%s", id.TenantID, id.UserID)
+		}
+		log.Printf("[%s] %s %s %v request_id=%s%s", r.Method, r.URL.Path, r.RemoteAddr, time.Since(start), r.Header.Get("X-Request-ID"), identity)
 	})
 }
 
@@ -22,7 +33,13 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
+				// RequestIDMiddleware runs inside this one and has already set
+				// the ID on the shared request headers
+				requestID := r.Header.Get("X-Request-ID")
+				log.Printf("Panic recovered: %v request_id=%s", err, requestID)
+				if requestID != "" {
+					w.Header().Set("X-Request-ID", requestID)
+				}
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
 			}
 		}()
@@ -30,11 +47,20 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// RequestIDMiddleware adds a unique request ID to each request
+// correlationIDPattern limits client-supplied correlation IDs to safe, log-friendly values
+var correlationIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware adds a request ID to each request. A valid client
+// X-Correlation-ID is used as the ID so a request can be traced end to end;
+// otherwise a unique one is generated.
 func RequestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestID := time.Now().UnixNano()
-		r.Header.Set("X-Request-ID", fmt.Sprintf("%d", requestID))
+		requestID := r.Header.Get("X-Correlation-ID")
+		if !correlationIDPattern.MatchString(requestID) {
+			requestID = fmt.Sprintf("%d", time.Now().UnixNano())
+		}
+		r.Header.Set("X-Request-ID", requestID)
+		w.Header().Set("X-Request-ID", requestID)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -44,7 +70,8 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Correlation-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Execution-Timeout-Ms, X-Request-ID")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -55,6 +82,68 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// MaintenanceResponse is returned by execution endpoints during maintenance
+type MaintenanceResponse struct {
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after"` // in seconds
+}
+
+// executionPaths are the endpoints that run code. They are refused during
+// maintenance and count towards the per-IP concurrency limit.
+var executionPaths = map[string]bool{
+	"/execute":      true,
+	"/execute/bulk": true,
+	"/submit":       true,
+	"/jobs":         true,
+	"/regrade":      true,
+	"/prewarm":      true,
+	"/stress":       true,
+}
+
+// MaintenanceMiddleware returns 503 for execution endpoints while enabled so
+// load balancers can drain traffic; every other route, including /health, is served
+func MaintenanceMiddleware(basePath string, enabled bool, retryAfter time.Duration, message string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !enabled || !executionPaths[strings.TrimPrefix(r.URL.Path, basePath)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			seconds := int(retryAfter.Seconds())
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(MaintenanceResponse{
+				Status:     "maintenance",
+				Message:    message,
+				RetryAfter: seconds,
+			})
+		})
+	}
+}
+
+// AdminAuthMiddleware requires "Authorization: Bearer <token>" on every
+// request. With an empty token the routes are disabled and answer 404.
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				http.NotFound(w, r)
+				return
+			}
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // RateLimiter tracks request counts
 type RateLimiter struct {
 	requests map[string][]time.Time
@@ -72,11 +161,11 @@ func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
 	}
 }
 
-// RateLimitMiddleware limits requests per IP
+// RateLimitMiddleware limits requests per client (tenant or IP)
 func RateLimitMiddleware(next http.Handler) http.Handler {
 	limiter := NewRateLimiter(100, time.Minute) // 100 requests per minute
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
+		ip := clientKey(r)
 		limiter.mu.Lock()
 		now := time.Now()
 		windowStart := now.Add(-limiter.window)
@@ -105,6 +194,91 @@ func RateLimitMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// inFlight counts execution requests being served across all clients
+var inFlight atomic.Int64
+
+// InFlightExecutions returns the number of execution requests currently being served
+func InFlightExecutions() int64 {
+	return inFlight.Load()
+}
+
+// ConcurrencyLimiter counts in-flight executions per client IP
+type ConcurrencyLimiter struct {
+	inflight map[string]int
+	mu       sync.Mutex
+	limit    int
+}
+
+// NewConcurrencyLimiter creates a limiter allowing limit in-flight executions per IP
+func NewConcurrencyLimiter(limit int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		inflight: make(map[string]int),
+		limit:    limit,
+	}
+}
+
+// acquire takes an execution slot for ip, reporting whether one was free
+func (l *ConcurrencyLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[ip] >= l.limit {
+		return false
+	}
+	l.inflight[ip]++
+	return true
+}
+
+// release frees a slot of ip. An IP with nothing in flight is removed, so
+// the map only ever holds currently active clients.
+func (l *ConcurrencyLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[ip] <= 1 {
+		delete(l.inflight, ip)
+		return
+	}
+	l.inflight[ip]--
+}
+
+// clientIP returns the IP part of the request's remote address
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ConcurrencyLimitMiddleware returns 429 when a client already has limit
+// executions in flight, and counts execution requests for
+// InFlightExecutions. Unlike the rate limiter it bounds simultaneous
+// executions rather than requests over time. A limit of 0 disables it.
+func ConcurrencyLimitMiddleware(basePath string, limit int) func(http.Handler) http.Handler {
+	limiter := NewConcurrencyLimiter(limit)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !executionPaths[strings.TrimPrefix(r.URL.Path, basePath)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			inFlight.Add(1)
+			defer inFlight.Add(-1)
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := clientKey(r)
+			if !limiter.acquire(ip) {
+				http.Error(w, "Too many concurrent executions from this client", http.StatusTooManyRequests)
+				return
+			}
+			defer limiter.release(ip)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureLog collects what the middleware logs until the test ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	logged := captureLog(t)
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	// Recovery wraps RequestIDMiddleware as in main
+	handler := RecoveryMiddleware(RequestIDMiddleware(panicking))
+
+	r := httptest.NewRequest(http.MethodPost, "/execute", nil)
+	r.Header.Set("X-Correlation-ID", "trace-42")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "trace-42" {
+		t.Errorf("X-Request-ID = %q, want the request's ID echoed", got)
+	}
+	if !strings.Contains(logged.String(), "Panic recovered: boom request_id=trace-42") {
+		t.Errorf("the panic was not logged with its request ID: %q", logged)
+	}
+}
+
+func TestRecoveryMiddlewarePassesThrough(t *testing.T) {
+	handler := RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "OK" {
+		t.Errorf("response = %d %q, want the handler's own", w.Code, w.Body)
+	}
+}
@@ -9,14 +9,241 @@ import (
 
 // Config holds the application configuration
 type Config struct {
-	Port         string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
-	IdleTimeout  time.Duration
-	RateLimit    int
-	RateWindow   time.Duration
-	MaxWorkers   int
-	MaxQueueSize int
+	Port          string
+	BasePath      string // Prefix for every route, e.g. "/api/compiler"; empty serves from the root
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	IdleTimeout   time.Duration
+	RateLimit     int
+	RateWindow    time.Duration
+	MaxWorkers    int
+	MaxQueueSize  int
+	MaxContainers int // Concurrent containers across single and batch executions
+	Image         string
+
+	// Stack size limit in MB for runs that request none, and the most a run may request
+	DefaultStackSizeMB int
+	MaxStackSizeMB     int
+
+	// Open file descriptors allowed per execution; 0 leaves the sandbox default
+	MaxOpenFiles int
+
+	// Retry-After sent when an execution is refused because MaxContainers
+	// executions are running and MaxQueueSize more are waiting
+	BusyRetryAfter time.Duration
+
+	// How long finished asynchronous jobs stay available for polling
+	JobTTL time.Duration
+
+	// Admission of large batch submissions: at most MaxLargeBatches submissions
+	// with LargeBatchCases or more test cases are graded at once (0 disables the limit)
+	LargeBatchCases      int
+	MaxLargeBatches      int
+	LargeBatchRetryAfter time.Duration
+
+	// In-flight executions allowed per client IP; 0 disables the limit
+	MaxConcurrentPerIP int
+
+	// Concurrent compilations; 0 compiles inline without a separate limit
+	MaxConcurrentCompiles int
+
+	// Maintenance mode: execution endpoints return 503 while /health stays up
+	MaintenanceMode       bool
+	MaintenanceRetryAfter time.Duration
+	MaintenanceMessage    string
+
+	// Deployment environment: "production" (default) or "development"
+	Environment string
+
+	// Include generated batch runner scripts in submit responses and logs.
+	// Ignored outside development so scripts never reach production clients.
+	DebugRunnerScript bool
+
+	// Keep a tarball of each batch sandbox directory in SandboxArchiveDir
+	// instead of only deleting it. Retains user code, so it is likewise
+	// ignored outside development.
+	DebugSandboxArchive bool
+	SandboxArchiveDir   string
+
+	// Sandbox backend: "docker" (default) or "local". Local mode runs code
+	// directly on the host with no isolation and is refused outside development.
+	SandboxMode string
+
+	// Docker client: binary to run and daemon to target; an empty host
+	// leaves the client's default socket in place
+	DockerPath string
+	DockerHost string
+
+	// Retries of container starts that fail for transient daemon reasons;
+	// the backoff doubles after each attempt
+	StartRetries      int
+	StartRetryBackoff time.Duration
+
+	// On a container name conflict the run is retried once under a fresh name.
+	// The conflicting container is removed only if stopped, unless this forces
+	// removal of running ones too.
+	ForceRemoveConflicts bool
+
+	// Idle containers kept running per enabled language so executions skip
+	// container startup; 0 starts a fresh container for every execution.
+	// A warm container is reset between executions rather than recreated, and
+	// reports its peak memory since it started rather than per execution.
+	WarmPoolSize int
+
+	// Attempts at preparing the sandbox at startup before giving up; the
+	// backoff doubles after each failed attempt
+	StartupRetries      int
+	StartupRetryBackoff time.Duration
+
+	// Sandbox image pull policy: "never" fails startup when the image is
+	// missing, "if-missing" pulls it in the background
+	ImagePullPolicy string
+
+	// Images pinned to language versions, keyed by "language@version",
+	// e.g. LANGUAGE_IMAGES="python@3.11=python:3.11-slim,python@3.12=python:3.12-slim".
+	// Requests without a version run on Image.
+	LanguageImages map[string]string
+
+	// Languages accepted for execution; empty enables every supported language
+	EnabledLanguages []string
+
+	// Whether to report memory_used_kb, the peak memory read from the container's cgroup
+	CollectContainerStats bool
+
+	// Per-request environment variables
+	AllowedEnvVars  []string
+	MaxEnvVars      int
+	MaxEnvValueSize int
+
+	// Additional source files accepted per request for multi-file programs
+	MaxSourceFiles int
+
+	// Bearer token required by the /admin endpoints; empty disables them
+	AdminToken string
+
+	// Identity headers set by an auth gateway, honored only on requests
+	// from TrustedProxies (IPs or CIDRs)
+	TrustedProxies []string
+	TenantHeader   string
+	UserHeader     string
+
+	// JSON file of per-language regular expressions that reject code before
+	// it runs; empty disables the scan
+	BannedPatternsFile string
+
+	// Output captured from a single run in bytes; a run printing more is stopped
+	MaxOutputBytes int
+	// Total output read back from all cases of a batch, in bytes
+	MaxBatchOutputSize int
+	// Output kept per test case in bytes; longer output is truncated
+	MaxCaseOutputSize int
+
+	// Batch sharding: default and maximum number of containers a batch is split across
+	BatchShards    int
+	MaxBatchShards int
+
+	// Bulk execution limits
+	MaxBulkRequests int // Programs accepted in one bulk request
+	BulkConcurrency int // Programs of one bulk request executing at once
+
+	// Stress testing limits
+	MaxStressIterations int
+	StressTimeout       time.Duration
+
+	// Per-test-case limit caps
+	MaxCaseTimeLimit   time.Duration
+	MaxCaseMemoryLimit int // in MB
+
+	// Result storage
+	ResultStore      string // memory, sqlite or postgres
+	ResultStoreDSN   string // sqlite file path or postgres connection URL
+	MaxStoredResults int    // capacity of the in-memory store
+
+	// Code size limits in bytes
+	MaxCodeSize    int            // Default for languages without their own limit
+	CodeSizeLimits map[string]int // Per-language overrides
+
+	// Input size limits in bytes; for a batch the limit applies to the total of all test case inputs
+	MaxInputSize    int            // Default for languages without their own limit
+	InputSizeLimits map[string]int // Per-language overrides
+
+	// Maximum decoded size of a precompiled artifact in bytes
+	MaxArtifactSize int
+
+	// Compile phase timeouts, separate from run timeouts
+	CompileTimeout  time.Duration            // Default for compiled languages without their own timeout
+	CompileTimeouts map[string]time.Duration // Per-language overrides
+
+	// Per-language time limit multipliers, e.g. to give Python 3x the C++ limit
+	TimeLimitMultipliers map[string]float64
+
+	// Container resource limits, e.g. to give the JVM more memory than Python
+	MemoryLimit  int            // in MB; default for languages without their own limit
+	MemoryLimits map[string]int // Per-language overrides
+	CPULimit     float64
+	CPULimits    map[string]float64
+	PidsLimit    int
+	PidsLimits   map[string]int
+
+	// Uploaded stdin payloads
+	MaxInputBlobSize int // in bytes
+	InputBlobTTL     time.Duration
+}
+
+// CodeSizeLimit returns the maximum code size in bytes for a language
+func (c *Config) CodeSizeLimit(language string) int {
+	if limit, ok := c.CodeSizeLimits[language]; ok {
+		return limit
+	}
+	return c.MaxCodeSize
+}
+
+// InputSizeLimit returns the maximum input size in bytes for a language
+func (c *Config) InputSizeLimit(language string) int {
+	if limit, ok := c.InputSizeLimits[language]; ok {
+		return limit
+	}
+	return c.MaxInputSize
+}
+
+// CompileTimeoutFor returns the compile phase timeout for a language
+func (c *Config) CompileTimeoutFor(language string) time.Duration {
+	if timeout, ok := c.CompileTimeouts[language]; ok {
+		return timeout
+	}
+	return c.CompileTimeout
+}
+
+// TimeLimitMultiplier returns the factor applied to time limits for a language
+func (c *Config) TimeLimitMultiplier(language string) float64 {
+	if multiplier, ok := c.TimeLimitMultipliers[language]; ok && multiplier > 0 {
+		return multiplier
+	}
+	return 1
+}
+
+// MemoryLimitFor returns the container memory limit in MB for a language
+func (c *Config) MemoryLimitFor(language string) int {
+	if limit, ok := c.MemoryLimits[language]; ok && limit > 0 {
+		return limit
+	}
+	return c.MemoryLimit
+}
+
+// CPULimitFor returns the number of CPUs a language's container may use
+func (c *Config) CPULimitFor(language string) float64 {
+	if limit, ok := c.CPULimits[language]; ok && limit > 0 {
+		return limit
+	}
+	return c.CPULimit
+}
+
+// PidsLimitFor returns the maximum number of processes in a language's container
+func (c *Config) PidsLimitFor(language string) int {
+	if limit, ok := c.PidsLimits[language]; ok && limit > 0 {
+		return limit
+	}
+	return c.PidsLimit
 }
 
 // LoadConfig loads configuration from environment variables with defaults
@@ -34,6 +261,12 @@ func LoadConfig() *Config {
 	writeTimeout := getDurationEnv("WRITE_TIMEOUT", 30*time.Second)
 	idleTimeout := getDurationEnv("IDLE_TIMEOUT", 120*time.Second)
 
+	// Get the route prefix, normalized to "/prefix" with no trailing slash
+	basePath := strings.Trim(getEnv("BASE_PATH", ""), "/")
+	if basePath != "" {
+		basePath = "/" + basePath
+	}
+
 	// Get rate limiting configuration
 	rateLimit := getIntEnv("RATE_LIMIT", 100) // requests per window
 	rateWindow := getDurationEnv("RATE_WINDOW", time.Minute)
@@ -41,16 +274,254 @@ func LoadConfig() *Config {
 	// Get worker pool configuration
 	maxWorkers := getIntEnv("MAX_WORKERS", 10)
 	maxQueueSize := getIntEnv("MAX_QUEUE_SIZE", 100)
+	maxContainers := getIntEnv("MAX_CONTAINERS", maxWorkers)
+	busyRetryAfter := getDurationEnv("BUSY_RETRY_AFTER", time.Second)
+
+	// Get asynchronous job retention
+	jobTTL := getDurationEnv("JOB_TTL", 10*time.Minute)
+
+	// Get stack size limits
+	defaultStackSizeMB := getIntEnv("DEFAULT_STACK_SIZE_MB", 8)
+	maxStackSizeMB := getIntEnv("MAX_STACK_SIZE_MB", 256)
+
+	// Get the open file limit
+	maxOpenFiles := getIntEnv("MAX_OPEN_FILES", 1024)
+	maxConcurrentCompiles := getIntEnv("MAX_CONCURRENT_COMPILES", 0)
+
+	// Get per-client concurrency limit
+	maxConcurrentPerIP := getIntEnv("MAX_CONCURRENT_PER_IP", 10)
+
+	// Get large batch admission settings
+	largeBatchCases := getIntEnv("LARGE_BATCH_CASES", 20)
+	maxLargeBatches := getIntEnv("MAX_LARGE_BATCHES", 0)
+	largeBatchRetryAfter := getDurationEnv("LARGE_BATCH_RETRY_AFTER", 5*time.Second)
+
+	// Get maintenance mode settings
+	maintenanceMode := getBoolEnv("MAINTENANCE_MODE", false)
+	maintenanceRetryAfter := getDurationEnv("MAINTENANCE_RETRY_AFTER", 60*time.Second)
+	maintenanceMessage := getEnv("MAINTENANCE_MESSAGE", "The service is undergoing maintenance, please try again shortly")
+
+	// Get deployment environment and sandbox backend
+	environment := getEnv("APP_ENV", "production")
+	sandboxMode := getEnv("SANDBOX_MODE", "docker")
+	debugRunnerScript := getBoolEnv("DEBUG_RUNNER_SCRIPT", false) && environment == "development"
+	debugSandboxArchive := getBoolEnv("DEBUG_SANDBOX_ARCHIVE", false) && environment == "development"
+	sandboxArchiveDir := getEnv("SANDBOX_ARCHIVE_DIR", "sandbox_archives")
+
+	// Get docker client configuration
+	dockerPath := getEnv("DOCKER_PATH", "docker")
+	dockerHost := getEnv("DOCKER_HOST", "")
+	startRetries := getIntEnv("START_RETRIES", 2)
+	startRetryBackoff := getDurationEnv("START_RETRY_BACKOFF", 200*time.Millisecond)
+	forceRemoveConflicts := getBoolEnv("FORCE_REMOVE_CONFLICTS", false)
+	warmPoolSize := getIntEnv("WARM_POOL_SIZE", 0)
+	startupRetries := getIntEnv("STARTUP_RETRIES", 5)
+	startupRetryBackoff := getDurationEnv("STARTUP_RETRY_BACKOFF", time.Second)
+
+	// Get the sandbox image used for executions
+	image := getEnv("COMPILER_IMAGE", "compiler-image")
+	imagePullPolicy := getEnv("IMAGE_PULL_POLICY", "never")
+	languageImages := getStringMapEnv("LANGUAGE_IMAGES")
+
+	// Get enabled languages, e.g. ENABLED_LANGUAGES="python,cpp"
+	enabledLanguages := getListEnv("ENABLED_LANGUAGES", nil)
+
+	// Get container stats collection setting
+	collectContainerStats := getBoolEnv("COLLECT_CONTAINER_STATS", true)
+
+	// Get per-request environment variable limits
+	allowedEnvVars := getListEnv("ALLOWED_ENV_VARS", []string{"MODE"})
+	maxEnvVars := getIntEnv("MAX_ENV_VARS", 10)
+	maxEnvValueSize := getIntEnv("MAX_ENV_VALUE_SIZE", 1024)
+
+	// Get the multi-file program limit
+	maxSourceFiles := getIntEnv("MAX_SOURCE_FILES", 50)
+
+	// Get the admin endpoint token
+	adminToken := getEnv("ADMIN_TOKEN", "")
+
+	// Get the gateway identity settings
+	trustedProxies := getListEnv("TRUSTED_PROXIES", nil)
+	tenantHeader := getEnv("TENANT_HEADER", "X-Tenant-ID")
+	userHeader := getEnv("USER_HEADER", "X-User-ID")
+
+	// Get the opt-in static code scan
+	bannedPatternsFile := getEnv("BANNED_PATTERNS_FILE", "")
+
+	// Get the batch output budget
+	maxOutputBytes := getIntEnv("MAX_OUTPUT_BYTES", 1024*1024)
+	maxBatchOutputSize := getIntEnv("MAX_BATCH_OUTPUT_SIZE", 16*1024*1024)
+	maxCaseOutputSize := getIntEnv("MAX_CASE_OUTPUT_SIZE", 1024*1024)
+
+	// Get batch sharding configuration
+	batchShards := getIntEnv("BATCH_SHARDS", 1)
+	maxBatchShards := getIntEnv("MAX_BATCH_SHARDS", 8)
+
+	// Get bulk execution limits
+	maxBulkRequests := getIntEnv("MAX_BULK_REQUESTS", 50)
+	bulkConcurrency := getIntEnv("BULK_CONCURRENCY", maxWorkers)
+
+	// Get stress testing limits
+	maxStressIterations := getIntEnv("MAX_STRESS_ITERATIONS", 500)
+	stressTimeout := getDurationEnv("STRESS_TIMEOUT", 60*time.Second)
+
+	// Get per-test-case limit caps
+	maxCaseTimeLimit := getDurationEnv("MAX_CASE_TIME_LIMIT", 10*time.Second)
+	maxCaseMemoryLimit := getIntEnv("MAX_CASE_MEMORY_LIMIT_MB", 1024)
+
+	// Get result storage configuration
+	resultStore := getEnv("RESULT_STORE", "memory")
+	resultStoreDSN := getEnv("RESULT_STORE_DSN", "")
+	maxStoredResults := getIntEnv("MAX_STORED_RESULTS", 1000)
+
+	// Get code size limits, e.g. CODE_SIZE_LIMITS="python=65536,c=4194304"
+	maxCodeSize := getIntEnv("MAX_CODE_SIZE", 1024*1024)
+	codeSizeLimits := getIntMapEnv("CODE_SIZE_LIMITS")
+
+	// Get input size limits, e.g. INPUT_SIZE_LIMITS="cpp=5242880"
+	maxInputSize := getIntEnv("MAX_INPUT_SIZE", 1024*1024)
+	inputSizeLimits := getIntMapEnv("INPUT_SIZE_LIMITS")
+
+	// Get precompiled artifact limit
+	maxArtifactSize := getIntEnv("MAX_ARTIFACT_SIZE", 8*1024*1024)
+
+	// Get compile timeouts, e.g. COMPILE_TIMEOUTS="cpp=60s,java=20s"
+	compileTimeout := getDurationEnv("COMPILE_TIMEOUT", 10*time.Second)
+	compileTimeouts := getDurationMapEnv("COMPILE_TIMEOUTS")
+
+	// Get time limit multipliers, e.g. TIME_LIMIT_MULTIPLIERS="python=3,java=2"
+	timeLimitMultipliers := getFloatMapEnv("TIME_LIMIT_MULTIPLIERS")
+
+	// Get container resource limits, e.g. MEMORY_LIMITS="java=1024,python=256"
+	memoryLimit := getIntEnv("MEMORY_LIMIT_MB", 512)
+	memoryLimits := getIntMapEnv("MEMORY_LIMITS")
+	cpuLimit := getFloatEnv("CPU_LIMIT", 1)
+	cpuLimits := getFloatMapEnv("CPU_LIMITS")
+	pidsLimit := getIntEnv("PIDS_LIMIT", 100)
+	pidsLimits := getIntMapEnv("PIDS_LIMITS")
+
+	// Get uploaded input limits
+	maxInputBlobSize := getIntEnv("MAX_INPUT_BLOB_SIZE", 16*1024*1024)
+	inputBlobTTL := getDurationEnv("INPUT_BLOB_TTL", 30*time.Minute)
 
 	return &Config{
-		Port:         port,
-		ReadTimeout:  readTimeout,
-		WriteTimeout: writeTimeout,
-		IdleTimeout:  idleTimeout,
-		RateLimit:    rateLimit,
-		RateWindow:   rateWindow,
-		MaxWorkers:   maxWorkers,
-		MaxQueueSize: maxQueueSize,
+		Port:          port,
+		BasePath:      basePath,
+		ReadTimeout:   readTimeout,
+		WriteTimeout:  writeTimeout,
+		IdleTimeout:   idleTimeout,
+		RateLimit:     rateLimit,
+		RateWindow:    rateWindow,
+		MaxWorkers:    maxWorkers,
+		MaxQueueSize:  maxQueueSize,
+		MaxContainers: maxContainers,
+		Image:         image,
+
+		DefaultStackSizeMB: defaultStackSizeMB,
+		MaxStackSizeMB:     maxStackSizeMB,
+
+		MaxOpenFiles: maxOpenFiles,
+
+		BusyRetryAfter: busyRetryAfter,
+
+		JobTTL: jobTTL,
+
+		MaxConcurrentPerIP: maxConcurrentPerIP,
+
+		LargeBatchCases:      largeBatchCases,
+		MaxLargeBatches:      maxLargeBatches,
+		LargeBatchRetryAfter: largeBatchRetryAfter,
+
+		MaxConcurrentCompiles: maxConcurrentCompiles,
+
+		MaintenanceMode:       maintenanceMode,
+		MaintenanceRetryAfter: maintenanceRetryAfter,
+		MaintenanceMessage:    maintenanceMessage,
+
+		Environment:       environment,
+		DebugRunnerScript: debugRunnerScript,
+
+		DebugSandboxArchive: debugSandboxArchive,
+		SandboxArchiveDir:   sandboxArchiveDir,
+
+		SandboxMode: sandboxMode,
+
+		DockerPath: dockerPath,
+		DockerHost: dockerHost,
+
+		StartRetries:      startRetries,
+		StartRetryBackoff: startRetryBackoff,
+
+		ForceRemoveConflicts: forceRemoveConflicts,
+
+		WarmPoolSize: warmPoolSize,
+
+		StartupRetries:      startupRetries,
+		StartupRetryBackoff: startupRetryBackoff,
+
+		ImagePullPolicy: imagePullPolicy,
+		LanguageImages:  languageImages,
+
+		EnabledLanguages: enabledLanguages,
+
+		CollectContainerStats: collectContainerStats,
+
+		AllowedEnvVars:  allowedEnvVars,
+		MaxEnvVars:      maxEnvVars,
+		MaxEnvValueSize: maxEnvValueSize,
+
+		MaxSourceFiles: maxSourceFiles,
+
+		AdminToken: adminToken,
+
+		TrustedProxies: trustedProxies,
+		TenantHeader:   tenantHeader,
+		UserHeader:     userHeader,
+
+		BannedPatternsFile: bannedPatternsFile,
+
+		MaxOutputBytes:     maxOutputBytes,
+		MaxBatchOutputSize: maxBatchOutputSize,
+		MaxCaseOutputSize:  maxCaseOutputSize,
+
+		BatchShards:    batchShards,
+		MaxBatchShards: maxBatchShards,
+
+		MaxBulkRequests: maxBulkRequests,
+		BulkConcurrency: bulkConcurrency,
+
+		MaxStressIterations: maxStressIterations,
+		StressTimeout:       stressTimeout,
+
+		MaxCaseTimeLimit:   maxCaseTimeLimit,
+		MaxCaseMemoryLimit: maxCaseMemoryLimit,
+
+		ResultStore:      resultStore,
+		ResultStoreDSN:   resultStoreDSN,
+		MaxStoredResults: maxStoredResults,
+
+		MaxCodeSize:    maxCodeSize,
+		CodeSizeLimits: codeSizeLimits,
+
+		MaxInputSize:    maxInputSize,
+		InputSizeLimits: inputSizeLimits,
+
+		MaxArtifactSize: maxArtifactSize,
+
+		CompileTimeout:  compileTimeout,
+		CompileTimeouts: compileTimeouts,
+
+		TimeLimitMultipliers: timeLimitMultipliers,
+
+		MemoryLimit:  memoryLimit,
+		MemoryLimits: memoryLimits,
+		CPULimit:     cpuLimit,
+		CPULimits:    cpuLimits,
+		PidsLimit:    pidsLimit,
+		PidsLimits:   pidsLimits,
+
+		MaxInputBlobSize: maxInputBlobSize,
+		InputBlobTTL:     inputBlobTTL,
 	}
 }
 
@@ -74,6 +545,92 @@ func getIntEnv(key string, defaultVal int) int {
 	return defaultVal
 }
 
+// getFloatEnv gets a number from environment variable with default
+func getFloatEnv(key string, defaultVal float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		if floatVal, err := strconv.ParseFloat(val, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultVal
+}
+
+// getListEnv gets a comma-separated list from environment variable with default
+func getListEnv(key string, defaultVal []string) []string {
+	if val := os.Getenv(key); val != "" {
+		var list []string
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+	return defaultVal
+}
+
+// getIntMapEnv gets a comma-separated list of key=integer pairs from environment variable.
+// Malformed entries are ignored.
+func getIntMapEnv(key string) map[string]int {
+	values := make(map[string]int)
+	for _, item := range getListEnv(key, nil) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if intVal, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+			values[strings.TrimSpace(parts[0])] = intVal
+		}
+	}
+	return values
+}
+
+// getFloatMapEnv gets a comma-separated list of key=number pairs from environment variable.
+// Malformed entries are ignored.
+func getFloatMapEnv(key string) map[string]float64 {
+	values := make(map[string]float64)
+	for _, item := range getListEnv(key, nil) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if floatVal, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
+			values[strings.TrimSpace(parts[0])] = floatVal
+		}
+	}
+	return values
+}
+
+// getDurationMapEnv gets a comma-separated list of key=duration pairs from environment variable.
+// Malformed entries are ignored.
+func getDurationMapEnv(key string) map[string]time.Duration {
+	values := make(map[string]time.Duration)
+	for _, item := range getListEnv(key, nil) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if duration, err := time.ParseDuration(strings.TrimSpace(parts[1])); err == nil {
+			values[strings.TrimSpace(parts[0])] = duration
+		}
+	}
+	return values
+}
+
+// getStringMapEnv gets a comma-separated list of key=value pairs from environment variable.
+// Malformed entries are ignored.
+func getStringMapEnv(key string) map[string]string {
+	values := make(map[string]string)
+	for _, item := range getListEnv(key, nil) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			continue
+		}
+		values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return values
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
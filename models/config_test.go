@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestMemoryLimitsFromEnv(t *testing.T) {
+	t.Setenv("MEMORY_LIMIT_MB", "256")
+	t.Setenv("MEMORY_LIMITS", "java=1024,cpp=128")
+	c := LoadConfig()
+
+	tests := []struct {
+		language string
+		want     int
+	}{
+		{"python", 256},
+		{"java", 1024},
+		{"cpp", 128},
+	}
+	for _, tt := range tests {
+		if got := c.MemoryLimitFor(tt.language); got != tt.want {
+			t.Errorf("MemoryLimitFor(%q) = %d, want %d", tt.language, got, tt.want)
+		}
+	}
+}
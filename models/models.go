@@ -2,20 +2,56 @@ package models
 
 // ExecuteRequest represents a code execution request
 type ExecuteRequest struct {
-	Code     string `json:"code"`
-	Language string `json:"language"`
-	Input    string `json:"input,omitempty"`
+	Code     string            `json:"code"`
+	Language string            `json:"language"`
+	Input    string            `json:"input,omitempty"`
+	InputRef string            `json:"input_ref,omitempty"` // Reference to an uploaded input, used instead of Input
+	Env      map[string]string `json:"env,omitempty"`
+	NoStats  bool              `json:"no_stats,omitempty"` // Omit memory_used_kb from the response
+	Profile  bool              `json:"profile,omitempty"`  // Run under `time -v` and report detailed resource usage
+	Artifact *Artifact         `json:"artifact,omitempty"` // Precompiled program run instead of compiling Code
+	Files    []SourceFile      `json:"files,omitempty"`    // Further source files; Code may be omitted if one is the entry file
+	NoCache  bool              `json:"no_cache,omitempty"` // Always build and run afresh, for nondeterministic builds
+	Version  string            `json:"version,omitempty"`  // Language version pinned to its own image; empty uses the default image
+	// Stack size limit in MB, clamped to the configured maximum; 0 uses the default
+	StackSizeMB int `json:"stack_size_mb,omitempty"`
+	// Tenant and user named by a trusted auth gateway, never read from the body
+	TenantID string `json:"-"`
+	UserID   string `json:"-"`
+}
+
+// SourceFile is one file of a multi-file program, written at Path relative to /code
+type SourceFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// Artifact is a program compiled elsewhere, run without a compile step
+type Artifact struct {
+	Type string `json:"type"` // "jar" or "class" for java, "binary" (ELF) for c and cpp
+	Data string `json:"data"` // Base64-encoded contents
 }
 
 // TestInput represents a single test case input for batch execution
 type TestInput struct {
-	ID    string `json:"id"`
-	Input string `json:"input"`
+	ID            string `json:"id"`
+	Input         string `json:"input"`
+	TimeLimitMs   int    `json:"time_limit_ms,omitempty"`
+	MemoryLimitMB int    `json:"memory_limit_mb,omitempty"`
 }
 
 // BatchExecuteRequest represents a request to execute code against multiple test cases
 type BatchExecuteRequest struct {
-	Code      string      `json:"code"`
-	Language  string      `json:"language"`
-	TestCases []TestInput `json:"test_cases"`
-}
\ No newline at end of file
+	Code      string            `json:"code"`
+	Language  string            `json:"language"`
+	TestCases []TestInput       `json:"test_cases"`
+	Env       map[string]string `json:"env,omitempty"`
+	NoStats   bool              `json:"no_stats,omitempty"`
+	Shards    int               `json:"shards,omitempty"` // Containers to split the test cases across; 0 uses the configured default
+	Artifact  *Artifact         `json:"artifact,omitempty"`
+	Files     []SourceFile      `json:"files,omitempty"`
+	NoCache   bool              `json:"no_cache,omitempty"`
+	Version   string            `json:"version,omitempty"`
+	// Stack size limit in MB, clamped to the configured maximum; 0 uses the default
+	StackSizeMB int `json:"stack_size_mb,omitempty"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExecuteRequestJSONTags(t *testing.T) {
+	req := ExecuteRequest{Code: "print(input())", Language: "python", Input: "hello"}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	// Clients depend on these exact keys, in this order
+	want := `{"code":"print(input())","language":"python","input":"hello"}`
+	if string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	var decoded ExecuteRequest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, req) {
+		t.Errorf("round trip = %+v, want %+v", decoded, req)
+	}
+}
+
+func TestExecuteRequestOmitsEmptyInput(t *testing.T) {
+	data, err := json.Marshal(ExecuteRequest{Code: "main", Language: "go"})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if want := `{"code":"main","language":"go"}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+}
+
+func TestExecuteRequestRoundTripAllFields(t *testing.T) {
+	req := ExecuteRequest{
+		Code:        "int main() {}",
+		Language:    "cpp",
+		Input:       "1 2",
+		InputRef:    "ref",
+		Env:         map[string]string{"MODE": "fast"},
+		NoStats:     true,
+		Profile:     true,
+		Artifact:    &Artifact{Type: "binary", Data: "AAAA"},
+		Files:       []SourceFile{{Path: "util.h", Content: "#pragma once"}},
+		NoCache:     true,
+		Version:     "17",
+		StackSizeMB: 64,
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields)
+	for _, key := range []string{"code", "language", "input", "input_ref", "env", "no_stats", "profile",
+		"artifact", "files", "no_cache", "version", "stack_size_mb"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("marshalled request has no %q key: %s", key, data)
+		}
+	}
+
+	var decoded ExecuteRequest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, req) {
+		t.Errorf("round trip = %+v, want %+v", decoded, req)
+	}
+}
+
+func TestBatchExecuteRequestJSONTags(t *testing.T) {
+	var req BatchExecuteRequest
+	body := `{"code":"x","language":"python","test_cases":[{"id":"tc_1","input":"1","time_limit_ms":500,"memory_limit_mb":64}]}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := BatchExecuteRequest{
+		Code:      "x",
+		Language:  "python",
+		TestCases: []TestInput{{ID: "tc_1", Input: "1", TimeLimitMs: 500, MemoryLimitMB: 64}},
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Errorf("Unmarshal = %+v, want %+v", req, want)
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+// Verdict is a judge verdict for a submission or a single test case
+type Verdict string
+
+const (
+	VerdictAccepted            Verdict = "AC"  // Output matched the expected output
+	VerdictWrongAnswer         Verdict = "WA"  // Program ran but output did not match
+	VerdictTimeLimitExceeded   Verdict = "TLE" // Program exceeded the time limit
+	VerdictMemoryLimitExceeded Verdict = "MLE" // Program was killed for exceeding the memory limit
+	VerdictRuntimeError        Verdict = "RE"  // Program exited with a non-zero status
+	VerdictCompileError        Verdict = "CE"  // Program failed to compile
+	VerdictSystemError         Verdict = "SE"  // Execution failed for reasons unrelated to the program
+	VerdictOutputLimitExceeded Verdict = "OLE" // The case printed past the per-case cap, or the batch's output budget ran out before it was read
+)
@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ActiveExecution describes an execution that currently holds a container slot
+type ActiveExecution struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"` // "single" or "batch"
+	Language  string    `json:"language"`
+	Container string    `json:"container"` // Name of the container that runs the program
+	StartTime time.Time `json:"start_time"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+
+	cancel context.CancelFunc
+}
+
+// ErrExecutionNotFound is returned when killing an execution that is not running
+var ErrExecutionNotFound = errors.New("execution not found")
+
+// executionKey is the context key holding the ID of the tracked execution
+type executionKey struct{}
+
+// active holds the executions currently running, keyed by ID
+var active = struct {
+	sync.Mutex
+	executions map[string]ActiveExecution
+}{executions: make(map[string]ActiveExecution)}
+
+// trackExecution registers exec as running until done is called. The
+// execution must run under the returned context so KillExecution can stop it.
+func trackExecution(ctx context.Context, exec ActiveExecution) (context.Context, func()) {
+	ctx, exec.cancel = context.WithCancel(context.WithValue(ctx, executionKey{}, exec.ID))
+	active.Lock()
+	active.executions[exec.ID] = exec
+	active.Unlock()
+	return ctx, func() {
+		active.Lock()
+		delete(active.executions, exec.ID)
+		active.Unlock()
+		exec.cancel()
+	}
+}
+
+// setActiveContainer records name as the container running the execution
+// tracked by ctx, for runs that end up somewhere other than the container
+// named when tracking began, such as a warm container
+func setActiveContainer(ctx context.Context, name string) {
+	id, ok := ctx.Value(executionKey{}).(string)
+	if !ok {
+		return
+	}
+	active.Lock()
+	if exec, ok := active.executions[id]; ok {
+		exec.Container = name
+		active.executions[id] = exec
+	}
+	active.Unlock()
+}
+
+// KillExecution cancels the running execution with the given ID. The sandbox
+// then kills and removes its container, and the caller gets a cancellation error.
+func KillExecution(id string) (ActiveExecution, error) {
+	active.Lock()
+	exec, ok := active.executions[id]
+	active.Unlock()
+	if !ok {
+		return ActiveExecution{}, ErrExecutionNotFound
+	}
+	exec.cancel()
+	exec.ElapsedMs = time.Since(exec.StartTime).Milliseconds()
+	return exec, nil
+}
+
+// ActiveExecutions returns the running executions, longest-running first
+func ActiveExecutions() []ActiveExecution {
+	active.Lock()
+	list := make([]ActiveExecution, 0, len(active.executions))
+	for _, exec := range active.executions {
+		list = append(list, exec)
+	}
+	active.Unlock()
+
+	now := time.Now()
+	for i := range list {
+		list[i].ElapsedMs = now.Sub(list[i].StartTime).Milliseconds()
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartTime.Before(list[j].StartTime)
+	})
+	return list
+}
@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrServerBusy is returned when an execution cannot be admitted because the
+// runner already holds as many executions as it has containers and queue space
+var ErrServerBusy = errors.New("server is busy, please try again later")
+
+// admitted counts executions that have been admitted and not yet finished,
+// whether queued, waiting for a container slot or running
+var admitted atomic.Int64
+
+// admissionCapacity is the number of executions the runner holds at once:
+// one per container slot plus one per place in the request queue
+func admissionCapacity() int64 {
+	return int64(cap(containerSlots) + cap(requestChan))
+}
+
+// admit reserves room for one execution without blocking. It is the single
+// admission gate for single and batch executions: once MAX_CONTAINERS
+// executions are running and MAX_QUEUE_SIZE more are waiting, further
+// executions fail immediately with ErrServerBusy instead of waiting inside a
+// worker or on the container semaphore. A batch is admitted once, however
+// many shards it is split into. release must be called when the execution
+// is done.
+func admit() (release func(), err error) {
+	if admitted.Add(1) > admissionCapacity() {
+		admitted.Add(-1)
+		return nil, ErrServerBusy
+	}
+	return func() { admitted.Add(-1) }, nil
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// archiveSandbox stores a gzipped tarball of execDir (code, inputs, outputs
+// and runner script) in SANDBOX_ARCHIVE_DIR for reproducing grading bugs, and
+// returns its path. The archive retains user code, so it is only written when
+// DEBUG_SANDBOX_ARCHIVE is enabled in development. Failures are logged and
+// return an empty path; they never fail the execution.
+func archiveSandbox(execDir, execID string) string {
+	if !config.DebugSandboxArchive {
+		return ""
+	}
+	if err := os.MkdirAll(config.SandboxArchiveDir, 0755); err != nil {
+		log.Printf("[ERROR] Failed to create sandbox archive directory: %v", err)
+		return ""
+	}
+
+	path := filepath.Join(config.SandboxArchiveDir, execID+".tar.gz")
+	if err := writeArchive(path, execDir); err != nil {
+		log.Printf("[ERROR] Failed to archive sandbox %s: %v", execID, err)
+		os.Remove(path)
+		return ""
+	}
+	log.Printf("[DEBUG] Archived sandbox %s to %s", execID, path)
+	return path
+}
+
+// writeArchive writes the regular files under dir to a gzipped tarball at path
+func writeArchive(path, dir string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		name, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		src, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"online-compiler/models"
+	"os"
+	"path/filepath"
+)
+
+// ErrInvalidArtifact is returned when a precompiled artifact does not match its declared type or language
+var ErrInvalidArtifact = errors.New("invalid artifact")
+
+// artifactKind describes a type of precompiled artifact and how to run it
+type artifactKind struct {
+	FileName  string // Name the artifact is written under in /code
+	Run       string // Command that runs it
+	Magic     []byte // Leading bytes every artifact of this type starts with
+	Mode      os.FileMode
+	Languages []string // Languages the artifact may be submitted for
+}
+
+// artifactKinds lists the accepted precompiled artifact types
+var artifactKinds = map[string]artifactKind{
+	"jar": {
+		FileName:  "main.jar",
+		Run:       "java -cp /code/main.jar Main",
+		Magic:     []byte("PK\x03\x04"),
+		Mode:      0644,
+		Languages: []string{"java"},
+	},
+	"class": {
+		FileName:  "Main.class",
+		Run:       "java -cp /code Main",
+		Magic:     []byte{0xca, 0xfe, 0xba, 0xbe},
+		Mode:      0644,
+		Languages: []string{"java"},
+	},
+	"binary": {
+		FileName:  "a.out",
+		Run:       "/code/a.out",
+		Magic:     []byte("\x7fELF"),
+		Mode:      0755,
+		Languages: []string{"c", "cpp"},
+	},
+}
+
+// decodeArtifact decodes a and checks it against its type, the language and the size limit
+func decodeArtifact(language string, a *models.Artifact) (artifactKind, []byte, error) {
+	kind, ok := artifactKinds[a.Type]
+	if !ok {
+		return kind, nil, fmt.Errorf("%w: unsupported artifact type %q", ErrInvalidArtifact, a.Type)
+	}
+	allowed := false
+	for _, name := range kind.Languages {
+		if name == language {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return kind, nil, fmt.Errorf("%w: %s artifacts cannot be run as %s", ErrInvalidArtifact, a.Type, language)
+	}
+	// Reject oversized artifacts before spending memory on decoding them
+	if len(a.Data) > base64.StdEncoding.EncodedLen(config.MaxArtifactSize) {
+		return kind, nil, fmt.Errorf("%w: artifact exceeds maximum size of %d bytes", ErrInvalidArtifact, config.MaxArtifactSize)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(a.Data)
+	if err != nil {
+		return kind, nil, fmt.Errorf("%w: artifact data is not valid base64", ErrInvalidArtifact)
+	}
+	if len(data) > config.MaxArtifactSize {
+		return kind, nil, fmt.Errorf("%w: artifact exceeds maximum size of %d bytes", ErrInvalidArtifact, config.MaxArtifactSize)
+	}
+	if !bytes.HasPrefix(data, kind.Magic) {
+		return kind, nil, fmt.Errorf("%w: data is not a %s artifact", ErrInvalidArtifact, a.Type)
+	}
+	return kind, data, nil
+}
+
+// ValidateArtifact checks a precompiled artifact submitted for language
+func ValidateArtifact(language string, a *models.Artifact) error {
+	_, _, err := decodeArtifact(language, a)
+	return err
+}
+
+// writeArtifact writes a into execDir and returns lang changed to run it
+// directly, with no compile step
+func writeArtifact(lang Language, a *models.Artifact, execDir string) (Language, error) {
+	kind, data, err := decodeArtifact(lang.Name, a)
+	if err != nil {
+		return lang, err
+	}
+	if err := os.WriteFile(filepath.Join(execDir, kind.FileName), data, kind.Mode); err != nil {
+		return lang, fmt.Errorf("failed to write artifact: %w", err)
+	}
+	lang.Compile = ""
+	lang.Run = kind.Run
+	return lang, nil
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"online-compiler/models"
+)
+
+// RunSpec describes a single sandboxed run. Scripts refer to the execution
+// directory as /code regardless of where it lives on the host.
+type RunSpec struct {
+	Name          string            // Unique name for the run, used as the container name
+	Language      string            // Language of the program, used to pick a warm container
+	Dir           string            // Absolute host path of the execution directory
+	Script        string            // Shell script to run
+	Env           map[string]string // Per-request environment variables
+	MemoryLimitMB int
+	CPUs          float64 // CPUs the run may use; 0 uses the configured default
+	PidsLimit     int     // Maximum processes; 0 uses the configured default
+	StopTimeout   int     // Seconds to wait before force-stopping
+	Image         string  // Sandbox image; empty uses the default image. The local sandbox ignores it.
+	StackSizeMB   int     // Stack size limit; 0 leaves the sandbox default
+	OpenFiles     int     // Open file descriptor limit; 0 leaves the sandbox default
+}
+
+// Sandbox runs untrusted code. Every execution path goes through the
+// configured sandbox so handlers behave the same regardless of backend.
+type Sandbox interface {
+	// Prepare checks the backend is usable before traffic arrives
+	Prepare() error
+	// Available reports whether the backend can currently run code
+	Available() error
+	// Run executes spec and returns its combined output. When ctx ends first
+	// the run is stopped and ctx.Err() is returned.
+	Run(ctx context.Context, spec RunSpec) ([]byte, error)
+}
+
+// sandbox is the backend used for all executions
+var sandbox Sandbox = dockerSandbox{}
+
+// NewSandbox returns the sandbox selected by the configuration. The local
+// sandbox offers no isolation, so it is refused unless APP_ENV=development.
+func NewSandbox(c *models.Config) (Sandbox, error) {
+	switch c.SandboxMode {
+	case "", "docker":
+		return dockerSandbox{}, nil
+	case "local":
+		if c.Environment != "development" {
+			return nil, fmt.Errorf("SANDBOX_MODE=local is unsafe and only allowed with APP_ENV=development (got %q)", c.Environment)
+		}
+		return localSandbox{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sandbox mode: %s", c.SandboxMode)
+	}
+}
+
+// SetSandbox sets the backend used for executions
+func SetSandbox(s Sandbox) {
+	sandbox = s
+}
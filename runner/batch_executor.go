@@ -3,169 +3,515 @@ package runner
 import (
 	"context"
 	"fmt"
+	"log"
 	"online-compiler/models"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-// ExecuteBatchInDocker executes code against multiple test cases in a single container
-func ExecuteBatchInDocker(ctx context.Context, req models.BatchExecuteRequest) (map[string]string, error) {
-	// Record start time
-	startTime := time.Now()
+// waitingForInput replaces the output of a case that timed out after reading
+// all of its input, which usually means it expects more input than provided
+const waitingForInput = "Execution timed out after reading all of its input. Your code may be waiting for input that will not arrive."
+
+// outputLimitExceeded marks a case whose output was not read because the batch output budget ran out
+const outputLimitExceeded = "Output limit exceeded: the total output of this batch exceeded its budget"
+
+const defaultCaseTimeout = 5 * time.Second // Timeout for a test case without its own limit
+
+// testIDPattern restricts test case IDs, which are used in file paths and the
+// generated runner script, to characters that need no escaping
+var testIDPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// BatchResult is the outcome of a single test case in a batch
+type BatchResult struct {
+	ID     string
+	Output string // Standard output only, so diagnostics never affect comparison
+	Stderr string
+	// Output was cut at MAX_CASE_OUTPUT_SIZE; the program printed more
+	Truncated bool
+	TimeMs    int64          // Wall-clock run time of the case inside the container
+	MemKb     int64          // Peak container memory in KB, 0 when stats are disabled
+	Verdict   models.Verdict // Failure determined by the runner; empty when the output still has to be compared
+}
+
+// BatchMetrics breaks down where the time of a batch execution went and how much memory it used
+type BatchMetrics struct {
+	QueueWait time.Duration  // Waiting for a free container slot
+	Compile   time.Duration  // Compiling inside the container
+	Run       time.Duration  // Running all test cases inside the container
+	Memory    ContainerStats // Container memory after all test cases ran
+	Script    string         // Generated runner script, only set when DEBUG_RUNNER_SCRIPT is enabled
+	// Size of the compiled artifacts, 0 for interpreted languages
+	BinarySize int64
+	// Tarball of the sandbox directory, only set when DEBUG_SANDBOX_ARCHIVE is enabled
+	Archive string
+	// Sandbox image the cases ran on
+	Image string
+}
+
+// ExecuteBatchInDocker executes code against multiple test cases in a single
+// container. On success the results hold one entry per test case, in request order.
+func ExecuteBatchInDocker(ctx context.Context, req models.BatchExecuteRequest) ([]BatchResult, BatchMetrics, error) {
+	var metrics BatchMetrics
+	if !ImageReady() {
+		return nil, metrics, ErrWarmingUp
+	}
+	if !LanguageEnabled(req.Language) && LanguageSupported(req.Language) {
+		return nil, metrics, fmt.Errorf("%w: %s", ErrLanguageDisabled, req.Language)
+	}
+	if image, err := ResolveImage(req.Language, req.Version); err != nil {
+		return nil, metrics, err
+	} else if !imageAvailable(image) {
+		return nil, metrics, ErrWarmingUp
+	}
+
+	for _, tc := range req.TestCases {
+		if !testIDPattern.MatchString(tc.ID) {
+			return nil, metrics, fmt.Errorf("invalid test case ID: %q", tc.ID)
+		}
+	}
+
+	release, err := admit()
+	if err != nil {
+		return nil, metrics, err
+	}
+	defer release()
+
+	if shards := BatchShards(req.Shards, len(req.TestCases)); shards > 1 {
+		return executeSharded(ctx, req, shards)
+	}
+	return executeBatch(ctx, req, config.MaxBatchOutputSize)
+}
+
+// BatchShards returns how many containers a batch of cases test cases is
+// split across when shards are requested, 0 meaning the configured default
+func BatchShards(shards, cases int) int {
+	if shards == 0 {
+		shards = config.BatchShards
+	}
+	if shards > cases {
+		shards = cases
+	}
+	return shards
+}
+
+// executeSharded splits the test cases into contiguous shards that run in
+// parallel containers, bounded by the container semaphore, and merges their
+// results. Each shard compiles the code itself, so sharding trades container
+// and compile overhead for wall-clock time. A failed shard marks only its own
+// cases as failed; an error is returned only if every shard failed.
+func executeSharded(ctx context.Context, req models.BatchExecuteRequest, shards int) ([]BatchResult, BatchMetrics, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		results  = make([]BatchResult, len(req.TestCases))
+		metrics  BatchMetrics
+		failed   int
+		firstErr error
+	)
+
+	n := len(req.TestCases)
+	for i := 0; i < shards; i++ {
+		offset := i * n / shards
+		shardReq := req
+		shardReq.TestCases = req.TestCases[offset : (i+1)*n/shards]
+
+		wg.Add(1)
+		go func(offset int, shardReq models.BatchExecuteRequest) {
+			defer wg.Done()
+			// Each shard gets an equal part of the batch's output budget
+			shardResults, shardMetrics, err := executeBatch(ctx, shardReq, config.MaxBatchOutputSize/shards)
+
+			mu.Lock()
+			defer mu.Unlock()
+			metrics = mergeBatchMetrics(metrics, shardMetrics)
+			if err != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+				for j, tc := range shardReq.TestCases {
+					results[offset+j] = BatchResult{
+						ID:      tc.ID,
+						Output:  fmt.Sprintf("Execution error: %v", err),
+						Verdict: models.VerdictSystemError,
+					}
+				}
+				return
+			}
+			copy(results[offset:], shardResults)
+		}(offset, shardReq)
+	}
+	wg.Wait()
+
+	if failed == shards {
+		return nil, metrics, firstErr
+	}
+	return results, metrics, nil
+}
+
+// mergeBatchMetrics combines the metrics of shards that ran in parallel, so
+// each phase is as long as its slowest shard
+func mergeBatchMetrics(a, b BatchMetrics) BatchMetrics {
+	if b.QueueWait > a.QueueWait {
+		a.QueueWait = b.QueueWait
+	}
+	if b.Compile > a.Compile {
+		a.Compile = b.Compile
+	}
+	if b.Run > a.Run {
+		a.Run = b.Run
+	}
+	if b.Memory.MemoryPeakBytes > a.Memory.MemoryPeakBytes {
+		a.Memory = b.Memory
+	}
+	if b.Script != "" {
+		a.Script += b.Script
+	}
+	if b.BinarySize > a.BinarySize {
+		a.BinarySize = b.BinarySize
+	}
+	if b.Archive != "" {
+		if a.Archive != "" {
+			a.Archive += ","
+		}
+		a.Archive += b.Archive
+	}
+	if b.Image != "" {
+		a.Image = b.Image
+	}
+	return a
+}
+
+// executeBatch runs all test cases of req in a single container. Case outputs
+// are read until outputBudget bytes have been read in total; the cases after
+// that are marked as exceeding the output limit without being read.
+func executeBatch(ctx context.Context, req models.BatchExecuteRequest, outputBudget int) ([]BatchResult, BatchMetrics, error) {
+	var metrics BatchMetrics
 
 	// Create unique directory for this execution
-	execID := fmt.Sprintf("%d", time.Now().UnixNano())
+	execID := newExecID()
 	execDir := filepath.Join("sandbox", execID)
 
 	// Get absolute path of execution directory
 	absExecDir, err := filepath.Abs(execDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		return nil, metrics, fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
 	// Create execution directory
-	if err := os.MkdirAll(execDir, 0777); err != nil {
-		return nil, fmt.Errorf("failed to create execution directory: %w", err)
+	if err := createExecDir(execDir); err != nil {
+		return nil, metrics, fmt.Errorf("failed to create execution directory: %w", err)
 	}
 
 	// Clean up execution directory when done
 	defer os.RemoveAll(execDir)
 
 	// Get language specification
-	codeFile, _ := getLanguageSpec(req.Language)
-	if codeFile == "" {
-		return nil, fmt.Errorf("unsupported language: %s", req.Language)
+	lang, ok := languages[req.Language]
+	if !ok {
+		return nil, metrics, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, req.Language)
+	}
+	image, err := ResolveImage(req.Language, req.Version)
+	if err != nil {
+		return nil, metrics, err
+	}
+	metrics.Image = image
+
+	// Validate per-request environment variables
+	if err := ValidateEnv(req.Env); err != nil {
+		return nil, metrics, err
 	}
 
-	// Write code to file
-	filePath := filepath.Join(execDir, codeFile)
-	if err := os.WriteFile(filePath, []byte(req.Code), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write code file: %w", err)
+	// Write code and any further source files
+	if err := writeSources(execDir, lang, req.Code, req.Files); err != nil {
+		return nil, metrics, err
+	}
+
+	// Run a precompiled artifact as is, or reuse prewarmed build artifacts instead of compiling again
+	if req.Artifact != nil {
+		if lang, err = writeArtifact(lang, req.Artifact, execDir); err != nil {
+			return nil, metrics, err
+		}
+	} else if !req.NoCache && req.Version == "" && len(req.Files) == 0 && restoreCompiled(lang, req.Code, execDir) {
+		lang.Compile = ""
 	}
 
 	// Create test cases directory
 	testCasesDir := filepath.Join(execDir, "testcases")
 	if err := os.MkdirAll(testCasesDir, 0777); err != nil {
-		return nil, fmt.Errorf("failed to create test cases directory: %w", err)
+		return nil, metrics, fmt.Errorf("failed to create test cases directory: %w", err)
 	}
 
 	// Write test cases to files
 	for _, tc := range req.TestCases {
 		tcFilePath := filepath.Join(testCasesDir, tc.ID+".in")
 		if err := os.WriteFile(tcFilePath, []byte(tc.Input), 0644); err != nil {
-			return nil, fmt.Errorf("failed to write test case file: %w", err)
+			return nil, metrics, fmt.Errorf("failed to write test case file: %w", err)
+		}
+	}
+
+	// Wait for a free container slot
+	queueStart := time.Now()
+	if err := acquireContainerSlot(ctx); err != nil {
+		return nil, metrics, err
+	}
+	defer releaseContainerSlot()
+	metrics.QueueWait = time.Since(queueStart)
+	ctx, done := trackExecution(ctx, ActiveExecution{
+		ID:        execID,
+		Kind:      "batch",
+		Language:  req.Language,
+		Container: fmt.Sprintf("compiler_batch_%s", execID),
+		StartTime: time.Now(),
+	})
+	defer done()
+
+	// Under a compile limit, compile first so only that phase holds a compile slot
+	if splitCompile(lang) {
+		elapsed, err := compilePhase(ctx, lang, execID, absExecDir, image, req.Env)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, metrics, fmt.Errorf("execution failed: %w", err)
+			}
+			compileError := readOutputFile(filepath.Join(execDir, "compile_output.txt"))
+			return compileErrorResults(req.TestCases, compileError), metrics, nil
 		}
+		metrics.Compile = elapsed
+		lang.Compile = ""
 	}
 
-	// Create batch runner script based on language
-	runnerScript := createBatchRunnerScript(req.Language, len(req.TestCases))
-	runnerPath := filepath.Join(execDir, "run_tests.sh")
-	if err := os.WriteFile(runnerPath, []byte(runnerScript), 0755); err != nil {
-		return nil, fmt.Errorf("failed to write runner script: %w", err)
+	script := createBatchRunnerScript(lang, req.TestCases, StackLimit(req.StackSizeMB))
+	if config.DebugRunnerScript {
+		log.Printf("[DEBUG] Runner script for batch %s:\n%s", execID, script)
+		metrics.Script = script
+	}
+	if config.DebugSandboxArchive {
+		// Keep the script next to the files it ran against
+		os.WriteFile(filepath.Join(execDir, "run_tests.sh"), []byte(script), 0644)
 	}
 
-	// Create container name
-	containerName := fmt.Sprintf("compiler_batch_%s", execID)
+	output, err := sandbox.Run(ctx, RunSpec{
+		Name:          fmt.Sprintf("compiler_batch_%s", execID),
+		Language:      req.Language,
+		Dir:           absExecDir,
+		Script:        script,
+		Env:           req.Env,
+		MemoryLimitMB: batchMemoryLimit(req.Language, req.TestCases),
+		CPUs:          config.CPULimitFor(req.Language),
+		PidsLimit:     config.PidsLimitFor(req.Language),
+		StopTimeout:   5,
+		Image:         image,
+		StackSizeMB:   StackLimit(req.StackSizeMB),
+		OpenFiles:     config.MaxOpenFiles,
+	})
+	if lang.Compile != "" {
+		metrics.Compile = readPhaseTime(filepath.Join(execDir, "compile_ms"))
+	}
+	metrics.Run = readPhaseTime(filepath.Join(execDir, "run_ms"))
+	metrics.Memory = readMemoryStats(execDir)
+	metrics.BinarySize = binarySize(languages[req.Language], execDir)
+	metrics.Archive = archiveSandbox(execDir, execID)
 
-	// Run the code inside the container with resource limits
-	cmd := exec.CommandContext(ctx, "docker", "run", "--rm",
-		"--name", containerName,
-		"--memory=512m",         // Memory limit
-		"--cpus=1",              // CPU limit
-		"--network=none",        // No network access
-		"--pids-limit=100",      // Process limit
-		"--ulimit", "nproc=100", // Set process limit via ulimit
-		"--stop-timeout=5", // Force stop after 5 seconds if not responding
-		"-v", absExecDir+":/code",
-		"compiler-image",
-		"sh", "-c", "cd /code && ./run_tests.sh")
+	// Check if it's a compilation error
+	if _, statErr := os.Stat(filepath.Join(execDir, "compile_failed")); statErr == nil {
+		// Read compilation error
+		compileError, readErr := readSandboxFile(filepath.Join(execDir, "compile_error.txt"), int64(config.MaxBatchOutputSize))
+		if readErr == nil {
+			return compileErrorResults(req.TestCases, string(compileError)), metrics, nil
+		}
+	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// Check if it's a compilation error
-		compileErrorPath := filepath.Join(execDir, "compile_error.txt")
-		if _, statErr := os.Stat(compileErrorPath); statErr == nil {
-			// Read compilation error
-			compileError, readErr := os.ReadFile(compileErrorPath)
-			if readErr == nil {
-				// Return compilation error for all test cases
-				results := make(map[string]string)
-				for _, tc := range req.TestCases {
-					results[tc.ID] = "Compilation error: " + string(compileError)
-				}
-				return results, nil
-			}
+	// The script always exits 0, so a failure here means the container itself
+	// failed or was killed. Results are still used if any case produced output.
+	if err != nil && !hasCaseOutput(testCasesDir, req.TestCases) {
+		return nil, metrics, fmt.Errorf("execution failed: %w\nOutput: %s", err, string(output))
+	}
+
+	// Parse results from output files, within the output budget
+	results := make([]BatchResult, len(req.TestCases))
+	remaining := int64(outputBudget)
+	for i, tc := range req.TestCases {
+		results[i] = readCaseResult(testCasesDir, tc.ID, &remaining)
+	}
+
+	if !StatsEnabled(req.NoStats) {
+		return results, metrics, nil
+	}
+
+	// All cases share the container, so each reports the container's peak
+	for i := range results {
+		results[i].MemKb = metrics.Memory.MemoryUsed
+	}
+	return results, metrics, nil
+}
+
+// compileErrorResults reports compileError as the result of every test case
+func compileErrorResults(testCases []models.TestInput, compileError string) []BatchResult {
+	results := make([]BatchResult, len(testCases))
+	for i, tc := range testCases {
+		results[i] = BatchResult{
+			ID:      tc.ID,
+			Output:  "Compilation error: " + compileError,
+			Verdict: models.VerdictCompileError,
 		}
-		return nil, fmt.Errorf("execution failed: %w\nOutput: %s", err, string(output))
 	}
+	return results
+}
 
-	// Parse results from output files
-	results := make(map[string]string)
-	for _, tc := range req.TestCases {
-		outputPath := filepath.Join(testCasesDir, tc.ID+".out")
-		outputBytes, err := os.ReadFile(outputPath)
-		if err != nil {
-			results[tc.ID] = fmt.Sprintf("Failed to read output: %v", err)
-		} else {
-			results[tc.ID] = string(outputBytes)
+// readCaseResult reads the outcome of test case id from the files the runner
+// script left in testCasesDir. Output beyond the per-case cap is truncated,
+// and is read only while it fits in the remaining budget, which is reduced by
+// the bytes read.
+func readCaseResult(testCasesDir, id string, remaining *int64) BatchResult {
+	result := BatchResult{ID: id}
+	base := filepath.Join(testCasesDir, id)
+
+	exitCode, err := readExitCode(base + ".exit")
+	if err != nil {
+		// The case never finished, e.g. because the container was killed
+		result.Output = "Execution error: no result was produced for this test case"
+		result.Verdict = models.VerdictSystemError
+		return result
+	}
+	result.Verdict = caseVerdict(exitCode)
+	result.TimeMs = readPhaseTime(base + ".ms").Milliseconds()
+
+	limit := int64(config.MaxCaseOutputSize)
+	if info, err := os.Lstat(base + ".out"); err == nil {
+		size := info.Size()
+		if size > limit {
+			size = limit
+		}
+		if size > *remaining {
+			*remaining = 0
+			result.Output = outputLimitExceeded
+			result.Verdict = models.VerdictOutputLimitExceeded
+			return result
 		}
+		result.Truncated = info.Size() > limit
+	}
+	output, err := readSandboxFile(base+".out", limit)
+	if err != nil {
+		result.Output = fmt.Sprintf("Failed to read output: %v", err)
+		result.Verdict = models.VerdictSystemError
+		return result
+	}
+	*remaining -= int64(len(output))
+	result.Output = string(output)
+	if result.Truncated && result.Verdict == models.VerdictRuntimeError {
+		// The program failed writing to the pipe closed at the per-case cap
+		result.Verdict = models.VerdictOutputLimitExceeded
 	}
 
-	// Get memory usage
-	executeReq := models.ExecuteRequest{
-		Language: req.Language,
-		Code:     req.Code,
-		Input:    req.TestCases[0].Input, // Use the first test case input for memory stats
+	// Stderr shares the budget but never fails the case, so it is truncated instead
+	stderr, _ := readSandboxFile(base+".err", *remaining)
+	*remaining -= int64(len(stderr))
+	result.Stderr = string(stderr)
+	if result.Verdict == models.VerdictRuntimeError && exhaustedFiles(result.Stderr) {
+		result.Output += openFilesMessage() + "\n"
 	}
-	memoryUsage, err := GetContainerStats(ctx, executeReq)
+	return result
+}
+
+// readExitCode reads the exit status of a test case written by the runner script
+func readExitCode(path string) (int, error) {
+	data, err := readSandboxFile(path, maxNumberFileSize)
 	if err != nil {
-		return results, nil
+		return 0, err
 	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
 
-	// Append memory usage to results
-	for id, result := range results {
-		results[id] = fmt.Sprintf("%s\nMemory Used: %d KB", result, memoryUsage.MemoryUsed)
+// caseVerdict maps a test case's exit status to a verdict. A clean exit
+// returns no verdict, leaving the outcome to output comparison.
+func caseVerdict(exitCode int) models.Verdict {
+	switch exitCode {
+	case 0:
+		return ""
+	case 124:
+		return models.VerdictTimeLimitExceeded
+	case 137:
+		// SIGKILL inside the container is almost always the OOM killer
+		return models.VerdictMemoryLimitExceeded
+	default:
+		return models.VerdictRuntimeError
 	}
+}
 
-	// Calculate execution time
-	executionTime := time.Since(startTime).Milliseconds()
+// batchMemoryLimit returns the container memory limit in MB for a batch.
+// All cases share one container, so the container is sized to the largest
+// per-case limit, or the language's limit if that is larger; a case with a
+// smaller limit is therefore not isolated from the larger allowance. Running
+// each differing case in its own container would enforce limits exactly, at
+// the cost of one container startup per case.
+func batchMemoryLimit(language string, testCases []models.TestInput) int {
+	limit := config.MemoryLimitFor(language)
+	for _, tc := range testCases {
+		if tc.MemoryLimitMB > limit {
+			limit = tc.MemoryLimitMB
+		}
+	}
+	return limit
+}
+
+// CaseTimeLimit returns the effective timeout for a test case in language:
+// the case's own limit, or the default, scaled by the language's multiplier
+func CaseTimeLimit(language string, timeLimitMs int) time.Duration {
+	base := defaultCaseTimeout
+	if timeLimitMs > 0 {
+		base = time.Duration(timeLimitMs) * time.Millisecond
+	}
+	return ScaleTimeLimit(language, base)
+}
 
-	// Append execution time to results
-	for id, result := range results {
-		results[id] = fmt.Sprintf("%s\nExecution Time: %d ms", result, executionTime)
+// hasCaseOutput reports whether any test case wrote an output file
+func hasCaseOutput(testCasesDir string, testCases []models.TestInput) bool {
+	for _, tc := range testCases {
+		if _, err := os.Stat(filepath.Join(testCasesDir, tc.ID+".out")); err == nil {
+			return true
+		}
 	}
+	return false
+}
 
-	return results, nil
+// readPhaseTime reads a phase duration in milliseconds written by the runner script
+func readPhaseTime(path string) time.Duration {
+	data, err := readSandboxFile(path, maxNumberFileSize)
+	if err != nil {
+		return 0
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
 }
 
 // createBatchRunnerScript creates a shell script to run all test cases
-func createBatchRunnerScript(language string, numTestCases int) string {
+func createBatchRunnerScript(lang Language, testCases []models.TestInput, stackMB int) string {
 	var sb strings.Builder
 
 	sb.WriteString("#!/bin/sh\n\n")
 
-	// Compile code if needed
-	switch language {
-	case "java":
-		sb.WriteString("javac /code/Main.java\n")
-		sb.WriteString("if [ $? -ne 0 ]; then\n")
-		sb.WriteString("  echo \"Compilation error\" > /code/compile_error.txt\n")
-		sb.WriteString("  exit 1\n")
-		sb.WriteString("fi\n")
-	case "cpp":
-		sb.WriteString("g++ /code/main.cpp -o /code/a.out\n")
-		sb.WriteString("if [ $? -ne 0 ]; then\n")
-		sb.WriteString("  echo \"Compilation error\" > /code/compile_error.txt\n")
-		sb.WriteString("  exit 1\n")
-		sb.WriteString("fi\n")
-	case "c":
-		sb.WriteString("gcc /code/main.c -o /code/a.out\n")
-		sb.WriteString("if [ $? -ne 0 ]; then\n")
-		sb.WriteString("  echo \"Compilation error\" > /code/compile_error.txt\n")
-		sb.WriteString("  exit 1\n")
+	// Helper reporting the current time in milliseconds for phase timings
+	sb.WriteString("now_ms() {\n")
+	sb.WriteString("  echo $(( $(date +%s%N) / 1000000 ))\n")
+	sb.WriteString("}\n\n")
+
+	// Compile code if needed, recording how long compilation took
+	if lang.Compile != "" {
+		sb.WriteString("compile_start=$(now_ms)\n")
+		sb.WriteString(compileStep(lang, "/code/compile_error.txt") + "\n")
+		sb.WriteString("echo $(( $(now_ms) - compile_start )) > /code/compile_ms\n")
+		sb.WriteString("if [ $compile_status -ne 0 ]; then\n")
+		sb.WriteString("  touch /code/compile_failed\n")
+		sb.WriteString("  exit 0\n")
 		sb.WriteString("fi\n")
 	}
 
@@ -173,27 +519,33 @@ func createBatchRunnerScript(language string, numTestCases int) string {
 	sb.WriteString(`
 run_test_case() {
     id=$1
+    limit=$2
     echo "Running test case $id"
-    timeout 5s sh -c "cat /code/testcases/$id.in | `)
-
-	// Add language-specific execution command
-	switch language {
-	case "python":
-		sb.WriteString("python3 /code/main.py")
-	case "java":
-		sb.WriteString("java -cp /code Main")
-	case "cpp", "c":
-		sb.WriteString("/code/a.out")
-	case "javascript":
-		sb.WriteString("node /code/main.js")
-	case "go":
-		sb.WriteString("go run /code/main.go")
-	}
-
-	sb.WriteString(`" > /code/testcases/$id.out 2>&1
-    exit_code=$?
-    if [ $exit_code -eq 124 ]; then
+    # Redirect stdin from the file, through fd 3, so EOF is always delivered
+    # and the shell can see how much input the program consumed
+    exec 3< /code/testcases/$id.in
+    case_start=$(now_ms)
+    # Keep one byte past the per-case cap so truncation is detected; a program
+    # printing beyond it gets a broken pipe instead of filling the disk
+    { timeout "$limit" `)
+
+	// Use the registry's run command so single and batch executions run the program the same way
+	sb.WriteString(lang.Run)
+
+	sb.WriteString(` <&3 2> /code/testcases/$id.err; echo $? > /code/testcases/$id.exit; } | head -c ` +
+		strconv.Itoa(config.MaxCaseOutputSize+1) + ` > /code/testcases/$id.out
+    exit_code=$(cat /code/testcases/$id.exit)
+    echo $(( $(now_ms) - case_start )) > /code/testcases/$id.ms
+    echo $exit_code > /code/testcases/$id.exit
+    consumed=$(sed -n 's/^pos:[[:space:]]*//p' /proc/$$/fdinfo/3 2>/dev/null)
+    exec 3<&-
+    size=$(wc -c < /code/testcases/$id.in)
+    if [ $exit_code -eq 124 ] && [ "$size" -gt 0 ] && [ "${consumed:-0}" -ge "$size" ]; then
+        echo "` + waitingForInput + `" > /code/testcases/$id.out
+    elif [ $exit_code -eq 124 ]; then
         echo "Execution timed out. Your code may contain an infinite loop." > /code/testcases/$id.out
+    elif [ $exit_code -eq ` + strconv.Itoa(segfaultExitCode) + ` ]; then
+        echo "` + segfaultMessage(stackMB) + `" >> /code/testcases/$id.out
     elif [ $exit_code -ne 0 ]; then
         echo "Execution failed with exit code $exit_code" >> /code/testcases/$id.out
     fi
@@ -202,9 +554,17 @@ run_test_case() {
 `)
 
 	// Run each test case in sequence
-	for i := 0; i < numTestCases; i++ {
-		sb.WriteString(fmt.Sprintf("run_test_case tc_%d\n", i))
+	sb.WriteString("run_start=$(now_ms)\n")
+	for _, tc := range testCases {
+		sb.WriteString(fmt.Sprintf("run_test_case %s %.3fs\n", tc.ID, CaseTimeLimit(lang.Name, tc.TimeLimitMs).Seconds()))
 	}
+	sb.WriteString("echo $(( $(now_ms) - run_start )) > /code/run_ms\n")
+
+	// Record memory usage of the whole run
+	sb.WriteString(memoryProbe + "\n")
+
+	// Per-case failures are recorded in the .out files, so the script itself always succeeds
+	sb.WriteString("exit 0\n")
 
 	return sb.String()
 }
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"context"
+	"online-compiler/models"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBatchSeparatesStderrFromOutput(t *testing.T) {
+	useLocalSandbox(t, nil, "python3")
+
+	results, _, err := ExecuteBatchInDocker(context.Background(), models.BatchExecuteRequest{
+		Language: "python",
+		Code: "import sys\n" +
+			"print('debug: reading input', file=sys.stderr)\n" +
+			"n = int(input())\n" +
+			"print(n * 2)\n" +
+			"print('warning: done', file=sys.stderr)",
+		TestCases: []models.TestInput{{ID: "tc_1", Input: "21"}},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteBatchInDocker: %v", err)
+	}
+
+	result := results[0]
+	if result.Output != "42\n" {
+		t.Errorf("output = %q, want only stdout", result.Output)
+	}
+	if result.Stderr != "debug: reading input\nwarning: done\n" {
+		t.Errorf("stderr = %q, want the diagnostics", result.Stderr)
+	}
+	if result.Verdict != "" {
+		t.Errorf("verdict = %q, want none for a clean exit", result.Verdict)
+	}
+}
+
+func TestBatchRejectsMaliciousTestIDs(t *testing.T) {
+	useLocalSandbox(t, nil, "python3")
+
+	for _, id := range []string{"../../etc/passwd", "tc_1; rm -rf /", "$(id)", "tc 1", "tc\n1", ""} {
+		_, _, err := ExecuteBatchInDocker(context.Background(), models.BatchExecuteRequest{
+			Language:  "python",
+			Code:      "print(input())",
+			TestCases: []models.TestInput{{ID: "tc_1", Input: "1"}, {ID: id, Input: "2"}},
+		})
+		if err == nil || !strings.Contains(err.Error(), "invalid test case ID") {
+			t.Errorf("test case ID %q returned %v, want it rejected", id, err)
+		}
+	}
+
+	// Nothing was written before the IDs were checked
+	if entries, err := os.ReadDir("sandbox"); err == nil && len(entries) > 0 {
+		t.Errorf("rejected batches left %d sandbox directories", len(entries))
+	}
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// compileSlots bounds concurrent compilations when MAX_CONCURRENT_COMPILES is
+// set. Compilers are far more CPU-hungry than most programs, so with a limit
+// the compile step runs as its own sandbox phase before the program runs and
+// only that phase holds a compile slot. Nil leaves compilation inline.
+var compileSlots = newCompileSlots(config.MaxConcurrentCompiles)
+
+// newCompileSlots returns a semaphore of size limit, or nil when limit is not positive
+func newCompileSlots(limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	return make(chan struct{}, limit)
+}
+
+// splitCompile reports whether lang must be compiled in its own phase
+func splitCompile(lang Language) bool {
+	return compileSlots != nil && lang.Compile != ""
+}
+
+// acquireCompileSlot blocks until a compilation may start or ctx ends
+func acquireCompileSlot(ctx context.Context) error {
+	if compileSlots == nil {
+		return nil
+	}
+	select {
+	case compileSlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("request timed out waiting for a free compile slot")
+	}
+}
+
+// releaseCompileSlot frees a slot taken by acquireCompileSlot
+func releaseCompileSlot() {
+	if compileSlots != nil {
+		<-compileSlots
+	}
+}
+
+// compilePhase compiles the code already written to absExecDir in a sandbox
+// run of its own, writing compiler output to compile_output.txt, and returns
+// how long compilation took once a compile slot was free. The caller must
+// already hold a container slot; compile slots are only ever taken inside
+// one, so the two semaphores cannot deadlock.
+func compilePhase(ctx context.Context, lang Language, execID, absExecDir, image string, env map[string]string) (time.Duration, error) {
+	if err := acquireCompileSlot(ctx); err != nil {
+		return 0, err
+	}
+	defer releaseCompileSlot()
+
+	start := time.Now()
+	_, err := sandbox.Run(ctx, RunSpec{
+		Name:          fmt.Sprintf("compiler_compile_%s", execID),
+		Language:      lang.Name,
+		Dir:           absExecDir,
+		Script:        compileStep(lang, "/code/compile_output.txt") + "; exit $compile_status",
+		Env:           env,
+		MemoryLimitMB: config.MemoryLimitFor(lang.Name),
+		CPUs:          config.CPULimitFor(lang.Name),
+		PidsLimit:     config.PidsLimitFor(lang.Name),
+		StopTimeout:   10,
+		Image:         image,
+	})
+	return time.Since(start), err
+}
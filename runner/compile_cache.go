@@ -0,0 +1,183 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrNotCompiled is returned when prewarming a language that has no compile step
+	ErrNotCompiled = errors.New("language is not compiled")
+	// ErrCompileFailed is returned when prewarmed code does not compile
+	ErrCompileFailed = errors.New("compilation failed")
+)
+
+// compileCacheDir holds one directory of build artifacts per cache key
+const compileCacheDir = "compile_cache"
+
+// CompileCacheKey identifies the build artifacts of code in language
+func CompileCacheKey(language, code string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%s%d:%s", len(language), language, len(code), code)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Prewarm compiles code without running it and stores the artifacts in the
+// compile cache, returning the cache key. It is idempotent: already cached
+// code is not compiled again, which cached reports.
+func Prewarm(ctx context.Context, language, code string) (key string, cached bool, err error) {
+	if !ImageReady() {
+		return "", false, ErrWarmingUp
+	}
+	lang, ok := languages[language]
+	if !ok {
+		return "", false, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+	if !LanguageEnabled(language) {
+		return "", false, fmt.Errorf("%w: %s", ErrLanguageDisabled, language)
+	}
+	if lang.Compile == "" {
+		return "", false, fmt.Errorf("%w: %s", ErrNotCompiled, language)
+	}
+
+	key = CompileCacheKey(language, code)
+	cacheDir := filepath.Join(compileCacheDir, key)
+	if _, err := os.Stat(cacheDir); err == nil {
+		return key, true, nil
+	}
+
+	execID := newExecID()
+	execDir := filepath.Join("sandbox", execID)
+	absExecDir, err := filepath.Abs(execDir)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if err := createExecDir(execDir); err != nil {
+		return "", false, fmt.Errorf("failed to create execution directory: %w", err)
+	}
+	defer os.RemoveAll(execDir)
+
+	if err := os.WriteFile(filepath.Join(execDir, lang.FileName), sourceCode(code), 0644); err != nil {
+		return "", false, fmt.Errorf("failed to write code file: %w", err)
+	}
+
+	if err := acquireContainerSlot(ctx); err != nil {
+		return "", false, err
+	}
+	_, err = compilePhase(ctx, lang, execID, absExecDir, "", nil)
+	releaseContainerSlot()
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %v\nOutput: %s", ErrCompileFailed, err,
+			readOutputFile(filepath.Join(execDir, "compile_output.txt")))
+	}
+
+	if err := storeArtifacts(lang, execDir, cacheDir); err != nil {
+		return "", false, err
+	}
+	return key, false, nil
+}
+
+// storeArtifacts copies the build artifacts from execDir into cacheDir. They
+// are staged in a temporary directory and renamed into place so a partially
+// written entry is never visible and concurrent prewarms don't conflict.
+func storeArtifacts(lang Language, execDir, cacheDir string) error {
+	if err := os.MkdirAll(compileCacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create compile cache: %w", err)
+	}
+	staging, err := os.MkdirTemp(compileCacheDir, "staging_")
+	if err != nil {
+		return fmt.Errorf("failed to create compile cache entry: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	copied, err := copyArtifacts(lang, execDir, staging)
+	if err != nil {
+		return err
+	}
+	if copied == 0 {
+		return fmt.Errorf("compilation produced no artifacts")
+	}
+
+	if err := os.Rename(staging, cacheDir); err != nil {
+		// Another prewarm of the same code got there first
+		if _, statErr := os.Stat(cacheDir); statErr == nil {
+			return nil
+		}
+		return fmt.Errorf("failed to store compile cache entry: %w", err)
+	}
+	return nil
+}
+
+// restoreCompiled copies cached build artifacts for code into execDir,
+// reporting whether there were any so the compile step can be skipped
+func restoreCompiled(lang Language, code, execDir string) bool {
+	if lang.Compile == "" {
+		return false
+	}
+	copied, err := copyArtifacts(lang, filepath.Join(compileCacheDir, CompileCacheKey(lang.Name, code)), execDir)
+	return err == nil && copied > 0
+}
+
+// copyArtifacts copies the files matching lang's artifact patterns from src to dst
+func copyArtifacts(lang Language, src, dst string) (int, error) {
+	copied := 0
+	for _, pattern := range lang.Artifacts {
+		matches, err := filepath.Glob(filepath.Join(src, pattern))
+		if err != nil {
+			return copied, fmt.Errorf("invalid artifact pattern %q: %w", pattern, err)
+		}
+		for _, path := range matches {
+			if err := copyFile(path, filepath.Join(dst, filepath.Base(path))); err != nil {
+				return copied, fmt.Errorf("failed to copy artifact %s: %w", filepath.Base(path), err)
+			}
+			copied++
+		}
+	}
+	return copied, nil
+}
+
+// binarySize returns the total size in bytes of lang's build artifacts in
+// dir, or 0 for interpreted languages and when nothing was built
+func binarySize(lang Language, dir string) int64 {
+	var size int64
+	for _, pattern := range lang.Artifacts {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+	return size
+}
+
+// copyFile copies src to dst, keeping its permission bits so binaries stay
+// executable. src may have been written by the sandbox, so it must be a
+// regular file rather than a link to one.
+func copyFile(src, dst string) error {
+	in, err := openSandboxFile(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
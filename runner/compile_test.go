@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"context"
+	"online-compiler/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowCompiler is a compiled language whose compile step takes compileSeconds
+// and then "builds" the source into a shell script that the run step executes
+func slowCompiler(t *testing.T, compileSeconds string) {
+	languages["slowc"] = Language{
+		Name:      "slowc",
+		FileName:  "main.slow",
+		Compile:   "sh -c 'sleep " + compileSeconds + " && cp /code/main.slow /code/prog'",
+		Run:       "sh /code/prog",
+		Artifacts: []string{"prog"},
+	}
+	t.Cleanup(func() { delete(languages, "slowc") })
+}
+
+// compileTimeout sets slowc's compile timeout
+func compileTimeout(timeout time.Duration) func(c *models.Config) {
+	return func(c *models.Config) {
+		c.CompileTimeouts = map[string]time.Duration{"slowc": timeout}
+	}
+}
+
+func TestSingleCompileTimeout(t *testing.T) {
+	useLocalSandbox(t, compileTimeout(300*time.Millisecond))
+	slowCompiler(t, "5")
+
+	start := time.Now()
+	result := executeCodeWithContext(context.Background(), models.ExecuteRequest{Language: "slowc", Code: "echo ran", NoCache: true})
+	if result.Error == nil {
+		t.Fatalf("a compile past COMPILE_TIMEOUT succeeded with output %q", result.Output)
+	}
+	if !strings.Contains(result.CompileOutput, "Compilation timed out after 300ms") {
+		t.Errorf("compile output = %q, want a compile timeout message", result.CompileOutput)
+	}
+	if strings.Contains(result.Output, "ran") {
+		t.Errorf("the program ran after its compile timed out")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("compile was stopped after %v, want it stopped at its own 300ms timeout", elapsed)
+	}
+}
+
+func TestBatchCompileTimeout(t *testing.T) {
+	useLocalSandbox(t, compileTimeout(300*time.Millisecond))
+	slowCompiler(t, "5")
+
+	results, _, err := ExecuteBatchInDocker(context.Background(), models.BatchExecuteRequest{
+		Language:  "slowc",
+		Code:      "echo ran",
+		NoCache:   true,
+		TestCases: []models.TestInput{{ID: "tc_1"}, {ID: "tc_2"}},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteBatchInDocker: %v", err)
+	}
+	for _, result := range results {
+		if result.Verdict != models.VerdictCompileError || !strings.Contains(result.Output, "Compilation timed out") {
+			t.Errorf("case %s: verdict %q, output %q; want CE with a compile timeout message", result.ID, result.Verdict, result.Output)
+		}
+	}
+}
+
+func TestCompileTimeNotChargedToRunBudget(t *testing.T) {
+	useLocalSandbox(t, compileTimeout(5*time.Second))
+	// Compiling takes longer than the 500ms each case may run for
+	slowCompiler(t, "1.5")
+
+	results, metrics, err := ExecuteBatchInDocker(context.Background(), models.BatchExecuteRequest{
+		Language:  "slowc",
+		Code:      "echo ran",
+		NoCache:   true,
+		TestCases: []models.TestInput{{ID: "tc_1", TimeLimitMs: 500}, {ID: "tc_2", TimeLimitMs: 500}},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteBatchInDocker: %v", err)
+	}
+	for _, result := range results {
+		if result.Verdict != "" || result.Output != "ran\n" {
+			t.Errorf("case %s: verdict %q, output %q; want it to run normally after a slow compile", result.ID, result.Verdict, result.Output)
+		}
+	}
+	if metrics.Compile < time.Second {
+		t.Errorf("compile phase took %v, want the slow compile measured separately", metrics.Compile)
+	}
+}
@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"online-compiler/models"
+	"sort"
+	"sync"
+)
+
+// flightCall is an in-progress or completed execution shared by identical requests
+type flightCall struct {
+	done    chan struct{}
+	result  ExecutionResult
+	waiters int                // Callers still waiting for the result
+	cancel  context.CancelFunc // Cancels the execution once no caller is waiting
+}
+
+// flightGroup deduplicates concurrent executions with the same key
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// inflight shares executions between identical concurrent requests
+var inflight = &flightGroup{calls: make(map[string]*flightCall)}
+
+// Do runs fn once for all concurrent callers with the same key and returns its result to each of them.
+// The call is forgotten as soon as it completes, so a failure is never served to later requests.
+// A caller whose context ends stops waiting; the context passed to fn is
+// cancelled only when every caller has stopped waiting, and a later caller
+// then starts a fresh execution.
+func (g *flightGroup) Do(ctx context.Context, key string, fn func(ctx context.Context) ExecutionResult) ExecutionResult {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.Background())
+		call = &flightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			call.result = fn(callCtx)
+			g.mu.Lock()
+			g.forget(key, call)
+			g.mu.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result
+	case <-ctx.Done():
+		g.mu.Lock()
+		if call.waiters--; call.waiters == 0 {
+			g.forget(key, call)
+			call.cancel()
+		}
+		g.mu.Unlock()
+		return ExecutionResult{Error: contextError(ctx)}
+	}
+}
+
+// forget removes call from the group unless a newer call has replaced it.
+// g.mu must be held.
+func (g *flightGroup) forget(key string, call *flightCall) {
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
+
+// dedupKey identifies requests that are guaranteed to produce the same
+// execution. The caller's tenant and user are part of it, so a shared
+// execution's stats are recorded against the one caller that ran it.
+func dedupKey(req models.ExecuteRequest) string {
+	h := sha256.New()
+	var artifact models.Artifact
+	if req.Artifact != nil {
+		artifact = *req.Artifact
+	}
+	for _, part := range []string{req.Language, req.Code, req.Input, fmt.Sprint(req.NoStats), fmt.Sprint(req.Profile),
+		artifact.Type, artifact.Data, req.Version, fmt.Sprint(StackLimit(req.StackSizeMB)), req.TenantID, req.UserID} {
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+
+	keys := make([]string, 0, len(req.Env))
+	for key := range req.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	// Entry counts keep env variables and files from hashing alike
+	fmt.Fprintf(h, "%d:", len(keys))
+	for _, key := range keys {
+		fmt.Fprintf(h, "%d:%s=%d:%s", len(key), key, len(req.Env[key]), req.Env[key])
+	}
+	fmt.Fprintf(h, "%d:", len(req.Files))
+	for _, file := range req.Files {
+		fmt.Fprintf(h, "%d:%s=%d:%s", len(file.Path), file.Path, len(file.Content), file.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
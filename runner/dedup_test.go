@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"context"
+	"online-compiler/models"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDedupKeyStackSize(t *testing.T) {
+	base := models.ExecuteRequest{Language: "cpp", Code: "int main() {}"}
+	deep := base
+	deep.StackSizeMB = 64
+
+	if dedupKey(base) == dedupKey(deep) {
+		t.Fatalf("requests with stack sizes 0 and 64 share a dedup key")
+	}
+
+	// The default stack size is what a request without one runs under
+	explicit := base
+	explicit.StackSizeMB = config.DefaultStackSizeMB
+	if dedupKey(base) != dedupKey(explicit) {
+		t.Errorf("an explicit default stack size should share the key of no stack size")
+	}
+}
+
+func TestDedupKeyIdentity(t *testing.T) {
+	acme := models.ExecuteRequest{Language: "python", Code: "print(1)", TenantID: "acme", UserID: "alice"}
+	other := acme
+	other.TenantID = "globex"
+	bob := acme
+	bob.UserID = "bob"
+
+	if dedupKey(acme) == dedupKey(other) || dedupKey(acme) == dedupKey(bob) {
+		t.Errorf("requests from different callers share a dedup key, so only one would be accounted")
+	}
+}
+
+func TestFlightGroupDoesNotMergeStackSizes(t *testing.T) {
+	group := &flightGroup{calls: make(map[string]*flightCall)}
+	release := make(chan struct{})
+	var runs atomic.Int32
+
+	var wg sync.WaitGroup
+	for _, stack := range []int{8, 64} {
+		req := models.ExecuteRequest{Language: "cpp", Code: "int main() {}", StackSizeMB: stack}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			group.Do(context.Background(), dedupKey(req), func(ctx context.Context) ExecutionResult {
+				runs.Add(1)
+				<-release
+				return ExecutionResult{}
+			})
+		}()
+	}
+
+	// Both runs must start before either is released
+	deadline := time.Now().Add(2 * time.Second)
+	for runs.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := runs.Load(); got != 2 {
+		t.Fatalf("got %d executions for two stack sizes, want 2", got)
+	}
+}
+
+func TestFlightGroupCancelsWhenEveryCallerLeaves(t *testing.T) {
+	group := &flightGroup{calls: make(map[string]*flightCall)}
+	var runs atomic.Int32
+	cancelled := make(chan struct{}, 2)
+	fn := func(ctx context.Context) ExecutionResult {
+		runs.Add(1)
+		<-ctx.Done()
+		cancelled <- struct{}{}
+		return ExecutionResult{Error: ctx.Err()}
+	}
+
+	// Two callers share one execution
+	var wg sync.WaitGroup
+	cancels := make([]context.CancelFunc, 2)
+	for i := range cancels {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels[i] = cancel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			group.Do(ctx, "key", fn)
+		}()
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !flightWaiters(group, "key", 2) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancels[0]()
+	select {
+	case <-cancelled:
+		t.Fatal("the shared execution was cancelled while a caller was still waiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancels[1]()
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the shared execution kept running after every caller left")
+	}
+	wg.Wait()
+
+	// A new caller does not join the cancelled execution
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for runs.Load() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	group.Do(ctx, "key", fn)
+	if got := runs.Load(); got != 2 {
+		t.Errorf("got %d executions, want a fresh one for the new caller", got)
+	}
+}
+
+// flightWaiters reports whether the call for key has n waiting callers
+func flightWaiters(group *flightGroup, key string, n int) bool {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	call, ok := group.calls[key]
+	return ok && call.waiters == n
+}
@@ -0,0 +1,239 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// dockerCommand builds a docker CLI command using the configured binary and daemon
+func dockerCommand(args ...string) *exec.Cmd {
+	return withDockerEnv(exec.Command(config.DockerPath, args...))
+}
+
+// dockerCommandContext is like dockerCommand but kills the process when ctx is done
+func dockerCommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	return withDockerEnv(exec.CommandContext(ctx, config.DockerPath, args...))
+}
+
+// withDockerEnv points cmd at the configured DOCKER_HOST, if any
+func withDockerEnv(cmd *exec.Cmd) *exec.Cmd {
+	if config.DockerHost != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_HOST="+config.DockerHost)
+	}
+	return cmd
+}
+
+// dockerSandbox runs each execution in its own resource-limited container
+type dockerSandbox struct{}
+
+// Prepare checks the daemon and makes sure the sandbox image is available
+func (dockerSandbox) Prepare() error {
+	if err := CheckDockerAvailability(); err != nil {
+		return err
+	}
+	return PrepareImage()
+}
+
+// Available checks that the docker daemon is reachable
+func (dockerSandbox) Available() error {
+	return CheckDockerAvailability()
+}
+
+// transientStartErrors are docker daemon messages for container start
+// failures that are likely to succeed when retried
+var transientStartErrors = []string{
+	"cannot allocate memory",
+	"resource temporarily unavailable",
+	"Cannot connect to the Docker daemon",
+	"connection reset by peer",
+	"i/o timeout",
+	"TLS handshake timeout",
+}
+
+// isTransientStartError reports whether a docker run failed to start the
+// container for a transient reason. Docker exits with 125 when the error
+// comes from the daemon rather than from the program in the container, so
+// user program failures are never classified as transient.
+func isTransientStartError(output []byte, err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 125 {
+		return false
+	}
+	for _, msg := range transientStartErrors {
+		if strings.Contains(string(output), msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNameConflict reports whether a docker run failed because a container with
+// the requested name already exists
+func isNameConflict(output []byte, err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == 125 &&
+		strings.Contains(string(output), "is already in use")
+}
+
+// removeConflictingContainer removes the container holding name. Only a
+// stopped (stale) container is removed unless FORCE_REMOVE_CONFLICTS is set,
+// so a live container of another server sharing the daemon is left alone.
+func removeConflictingContainer(name string) {
+	args := []string{"rm", name}
+	if config.ForceRemoveConflicts {
+		args = []string{"rm", "-f", name}
+	}
+	if output, err := dockerCommand(args...).CombinedOutput(); err != nil {
+		log.Printf("[WARN] Could not remove conflicting container %s: %s", name, strings.TrimSpace(string(output)))
+	}
+}
+
+// Run starts a container with spec.Dir mounted at /code and runs the script
+// in it, or runs the script in an idle warm container when one matches. If
+// the container name is already taken, the conflicting container is cleaned
+// up and the run is retried once under a fresh name.
+func (s dockerSandbox) Run(ctx context.Context, spec RunSpec) ([]byte, error) {
+	if pool := warmPoolFor(spec); pool != nil {
+		if c := pool.acquire(); c != nil {
+			setActiveContainer(ctx, c.name)
+			output, err := pool.run(ctx, c, spec)
+			if !errors.Is(err, errWarmContainerGone) {
+				return output, err
+			}
+			log.Printf("[WARN] Warm container %s died, running %s in a fresh container", c.name, spec.Name)
+		}
+	}
+
+	setActiveContainer(ctx, spec.Name)
+	output, err := s.runWithRetries(ctx, spec)
+	if ctx.Err() != nil || !isNameConflict(output, err) {
+		return output, err
+	}
+
+	removeConflictingContainer(spec.Name)
+	name := spec.Name + "_" + newExecID()
+	log.Printf("[WARN] Container name %s already in use, retrying as %s", spec.Name, name)
+	spec.Name = name
+	setActiveContainer(ctx, spec.Name)
+	return s.runWithRetries(ctx, spec)
+}
+
+// runWithRetries runs spec, retrying with exponential backoff when the
+// container fails to start for a transient reason
+func (s dockerSandbox) runWithRetries(ctx context.Context, spec RunSpec) ([]byte, error) {
+	backoff := config.StartRetryBackoff
+	for attempt := 0; ; attempt++ {
+		output, err := s.runOnce(ctx, spec)
+		if err == nil || attempt >= config.StartRetries || ctx.Err() != nil || !isTransientStartError(output, err) {
+			return output, err
+		}
+
+		log.Printf("[WARN] Container %s failed to start (attempt %d), retrying in %v: %s",
+			spec.Name, attempt+1, backoff, strings.TrimSpace(string(output)))
+		// A failed start can leave the named container behind
+		dockerCommand("rm", "-f", spec.Name).Run()
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return output, err
+		}
+		backoff *= 2
+	}
+}
+
+// containerArgs returns the docker run flags that name the container and
+// apply spec's resource limits
+func containerArgs(spec RunSpec) []string {
+	cpus, pids := spec.CPUs, spec.PidsLimit
+	if cpus <= 0 {
+		cpus = config.CPULimit
+	}
+	if pids <= 0 {
+		pids = config.PidsLimit
+	}
+
+	args := []string{
+		"--name", spec.Name,
+		fmt.Sprintf("--memory=%dm", spec.MemoryLimitMB), // Memory limit
+		fmt.Sprintf("--cpus=%g", cpus),                  // CPU limit
+		"--network=none",                                // No network access
+		fmt.Sprintf("--pids-limit=%d", pids),            // Process limit
+		"--ulimit", fmt.Sprintf("nproc=%d", pids),       // Set process limit via ulimit
+		fmt.Sprintf("--stop-timeout=%d", spec.StopTimeout), // Force stop if not responding
+		"--pull=never", // Never pull on the request path
+	}
+	if spec.StackSizeMB > 0 {
+		stack := spec.StackSizeMB * 1024 * 1024
+		args = append(args, "--ulimit", fmt.Sprintf("stack=%d:%d", stack, stack))
+	}
+	if spec.OpenFiles > 0 {
+		args = append(args, "--ulimit", fmt.Sprintf("nofile=%d:%d", spec.OpenFiles, spec.OpenFiles))
+	}
+	return args
+}
+
+// runOnce makes a single attempt at running spec in a container
+func (dockerSandbox) runOnce(ctx context.Context, spec RunSpec) ([]byte, error) {
+	envArgs, err := buildEnvArgs(spec.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run the code inside the container with resource limits
+	args := append([]string{"run", "--rm"}, containerArgs(spec)...)
+	args = append(args, envArgs...)
+	image := spec.Image
+	if image == "" {
+		image = config.Image
+	}
+	args = append(args,
+		"-v", spec.Dir+":/code",
+		image,
+		"sh", "-c", spec.Script)
+
+	// Stop the run as soon as it prints more than the output limit
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	output := newCappedOutput(config.MaxOutputBytes, stop)
+	cmd := dockerCommandContext(runCtx, args...)
+	cmd.Stdout, cmd.Stderr = output, output
+
+	log.Printf("[DEBUG] Running Docker command: %s", strings.Join(redactEnvArgs(cmd.Args), " "))
+
+	// Run the command in a goroutine
+	done := make(chan struct{})
+	var cmdErr error
+	go func() {
+		cmdErr = cmd.Run()
+		close(done)
+	}()
+
+	// Wait for either the command to finish or the context to end
+	select {
+	case <-done:
+		if output.Exceeded() {
+			return output.Bytes(), ErrOutputLimitExceeded
+		}
+		return output.Bytes(), cmdErr
+	case <-runCtx.Done():
+		// Force kill the container
+		if err := dockerCommand("kill", spec.Name).Run(); err != nil {
+			log.Printf("[ERROR] Failed to kill container %s: %v", spec.Name, err)
+		}
+		// Force remove the container even if kill failed
+		if err := dockerCommand("rm", "-f", spec.Name).Run(); err != nil {
+			log.Printf("[ERROR] Failed to remove container %s: %v", spec.Name, err)
+		}
+		if output.Exceeded() {
+			return output.Bytes(), ErrOutputLimitExceeded
+		}
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,221 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"online-compiler/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyDockerScript stands in for the docker CLI. Each docker run pops the
+// first line of the failures file, "<exit code> <message>", and fails with it;
+// once the file is empty runs succeed.
+const flakyDockerScript = `#!/bin/sh
+dir=$(dirname "$0")
+echo "$*" >> "$dir/calls"
+if [ "$1" = run ] && [ -s "$dir/failures" ]; then
+	read -r code message < "$dir/failures"
+	sed -i 1d "$dir/failures"
+	echo "$message" >&2
+	exit "$code"
+fi
+[ "$1" = run ] && echo ok
+exit 0
+`
+
+// flakyDocker points the runner at flakyDockerScript, whose runs fail with
+// failures in order, and returns the directory holding its call log
+func flakyDocker(t *testing.T, failures ...string) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "docker")
+	if err := os.WriteFile(path, []byte(flakyDockerScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "failures"), []byte(strings.Join(failures, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	useConfig(t, func(c *models.Config) {
+		c.DockerPath = path
+		c.DockerHost = ""
+		c.StartRetries = 2
+		c.StartRetryBackoff = time.Millisecond
+	})
+	return dir
+}
+
+func TestRunWithRetries(t *testing.T) {
+	const (
+		transient = "125 docker: Error response from daemon: i/o timeout."
+		permanent = "125 docker: Error response from daemon: No such image: compiler:latest."
+		userError = "1 i/o timeout"
+	)
+	tests := []struct {
+		name      string
+		failures  []string
+		wantRuns  int
+		wantError bool
+	}{
+		{"success", nil, 1, false},
+		{"transient failures are retried", []string{transient, transient}, 3, false},
+		{"retries are bounded", []string{transient, transient, transient}, 3, true},
+		{"daemon errors that are not transient", []string{permanent}, 1, true},
+		{"program failures", []string{userError}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := flakyDocker(t, tt.failures...)
+
+			output, err := dockerSandbox{}.runWithRetries(context.Background(), RunSpec{Name: "exec_test", Dir: t.TempDir(), Script: "true"})
+			if (err != nil) != tt.wantError {
+				t.Errorf("runWithRetries() = %q, %v, want error: %v", output, err, tt.wantError)
+			}
+			if !tt.wantError && string(output) != "ok\n" {
+				t.Errorf("output = %q, want the successful run's", output)
+			}
+			if runs := len(dockerCalls(t, dir, "run")); runs != tt.wantRuns {
+				t.Errorf("docker run was called %d times, want %d", runs, tt.wantRuns)
+			}
+			// Each retry first removes what the failed start left behind
+			if removes := len(dockerCalls(t, dir, "rm")); removes != tt.wantRuns-1 {
+				t.Errorf("docker rm was called %d times, want %d", removes, tt.wantRuns-1)
+			}
+		})
+	}
+}
+
+// captureLog collects what the runner logs until the test ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestDockerLogRedactsEnv(t *testing.T) {
+	dir := flakyDocker(t)
+	useConfig(t, func(c *models.Config) { c.AllowedEnvVars = []string{"API_TOKEN"} })
+	logged := captureLog(t)
+
+	spec := RunSpec{Name: "exec_test", Dir: t.TempDir(), Script: "true", Env: map[string]string{"API_TOKEN": "s3cret"}}
+	if _, err := (dockerSandbox{}).runOnce(context.Background(), spec); err != nil {
+		t.Fatalf("runOnce: %v", err)
+	}
+
+	// The value still reaches docker, but not the log
+	if calls := dockerCalls(t, dir, "run"); len(calls) != 1 || !strings.Contains(calls[0], "-e API_TOKEN=s3cret") {
+		t.Errorf("docker run calls = %q, want the variable passed", calls)
+	}
+	if strings.Contains(logged.String(), "s3cret") {
+		t.Errorf("the docker command was logged with the variable's value: %s", logged)
+	}
+	if !strings.Contains(logged.String(), "-e API_TOKEN=<redacted>") {
+		t.Errorf("the docker command was not logged with the value redacted: %s", logged)
+	}
+}
+
+func TestRunRetriesNameConflict(t *testing.T) {
+	dir := flakyDocker(t, `125 docker: Error response from daemon: Conflict. The container name "/exec_test" is already in use by container "4f1c".`)
+	useConfig(t, func(c *models.Config) { c.AllowedEnvVars = []string{"API_TOKEN"} })
+	logged := captureLog(t)
+
+	spec := RunSpec{Name: "exec_test", Dir: t.TempDir(), Script: "true", Env: map[string]string{"API_TOKEN": "s3cret"}}
+	output, err := dockerSandbox{}.Run(context.Background(), spec)
+	if err != nil || string(output) != "ok\n" {
+		t.Fatalf("Run() = %q, %v, want the retried run's output", output, err)
+	}
+
+	// Only the stale container is removed, then the run is retried once under a new name
+	if removes := dockerCalls(t, dir, "rm"); len(removes) != 1 || removes[0] != "rm exec_test" {
+		t.Errorf("docker rm calls = %q, want one rm of exec_test", removes)
+	}
+	runs := dockerCalls(t, dir, "run")
+	if len(runs) != 2 {
+		t.Fatalf("docker run was called %d times, want 2", len(runs))
+	}
+	if !strings.Contains(runs[0], "--name exec_test ") || !strings.Contains(runs[1], "--name exec_test_") {
+		t.Errorf("docker run calls = %q, want the retry under a fresh name", runs)
+	}
+
+	if !strings.Contains(logged.String(), "Container name exec_test already in use, retrying as exec_test_") {
+		t.Errorf("the retry was not logged: %s", logged)
+	}
+	if strings.Contains(logged.String(), "s3cret") {
+		t.Errorf("a docker command was logged with the variable's value: %s", logged)
+	}
+	if redacted := strings.Count(logged.String(), "-e API_TOKEN=<redacted>"); redacted != 2 {
+		t.Errorf("%d docker commands were logged with the value redacted, want both runs: %s", redacted, logged)
+	}
+}
+
+// hasArg reports whether args contains arg
+func hasArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestContainerArgsMemoryLimit(t *testing.T) {
+	fake := useFakeSandbox(t, func(c *models.Config) {
+		c.MemoryLimit = 256
+		c.MemoryLimits = map[string]int{"java": 1024}
+	})
+	close(fake.release)
+
+	tests := []struct {
+		language string
+		code     string
+		want     string
+	}{
+		{"python", "print(1)", "--memory=256m"},
+		{"java", "public class Main { public static void main(String[] a) {} }", "--memory=1024m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			executeCodeWithContext(context.Background(), models.ExecuteRequest{Language: tt.language, Code: tt.code})
+
+			spec := <-fake.started
+			if args := containerArgs(spec); !hasArg(args, tt.want) {
+				t.Errorf("container args %q lack %s", args, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerArgsOpenFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		openFiles int
+		want      string // The nofile ulimit, empty when none should be set
+	}{
+		{"limit set", 64, "nofile=64:64"},
+		{"no limit", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := containerArgs(RunSpec{Name: "exec_1", MemoryLimitMB: 256, OpenFiles: tt.openFiles})
+
+			var nofile []string
+			for i := 0; i+1 < len(args); i++ {
+				if args[i] == "--ulimit" && strings.HasPrefix(args[i+1], "nofile=") {
+					nofile = append(nofile, args[i+1])
+				}
+			}
+			switch {
+			case tt.want == "" && len(nofile) > 0:
+				t.Errorf("container args %q set %q, want no nofile ulimit", args, nofile)
+			case tt.want != "" && (len(nofile) != 1 || nofile[0] != tt.want):
+				t.Errorf("container args %q set nofile ulimits %q, want --ulimit %s", args, nofile, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envKeyPattern restricts environment variable names to portable shell identifiers
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// buildEnvArgs converts per-request environment variables into docker "-e" arguments.
+// Each variable becomes its own argv entry so values never pass through a shell.
+func buildEnvArgs(env map[string]string) ([]string, error) {
+	if len(env) == 0 {
+		return nil, nil
+	}
+	if len(env) > config.MaxEnvVars {
+		return nil, fmt.Errorf("too many environment variables: %d (maximum %d)", len(env), config.MaxEnvVars)
+	}
+
+	// Sort keys so the generated docker command is deterministic
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		value := env[key]
+		if !envKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid environment variable name: %q", key)
+		}
+		if !isEnvAllowed(key) {
+			return nil, fmt.Errorf("environment variable not allowed: %s", key)
+		}
+		if len(value) > config.MaxEnvValueSize {
+			return nil, fmt.Errorf("environment variable %s exceeds maximum size of %d bytes", key, config.MaxEnvValueSize)
+		}
+		if strings.ContainsRune(value, 0) {
+			return nil, fmt.Errorf("environment variable %s contains a null byte", key)
+		}
+		args = append(args, "-e", key+"="+value)
+	}
+	return args, nil
+}
+
+// redactEnvArgs returns a copy of docker arguments with the value of every
+// "-e" variable replaced, so commands can be logged without request data
+func redactEnvArgs(args []string) []string {
+	redacted := append([]string(nil), args...)
+	for i := 1; i < len(redacted); i++ {
+		if redacted[i-1] != "-e" {
+			continue
+		}
+		if key, _, ok := strings.Cut(redacted[i], "="); ok {
+			redacted[i] = key + "=<redacted>"
+		}
+	}
+	return redacted
+}
+
+// isEnvAllowed reports whether key is in the configured allowlist
+func isEnvAllowed(key string) bool {
+	for _, allowed := range config.AllowedEnvVars {
+		if key == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateEnv checks per-request environment variables against the configured allowlist and limits
+func ValidateEnv(env map[string]string) error {
+	_, err := buildEnvArgs(env)
+	return err
+}
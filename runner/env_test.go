@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"context"
+	"online-compiler/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildEnvArgs(t *testing.T) {
+	useConfig(t, func(c *models.Config) {
+		c.AllowedEnvVars = []string{"MODE", "SEED"}
+		c.MaxEnvVars = 2
+		c.MaxEnvValueSize = 16
+	})
+
+	args, err := buildEnvArgs(map[string]string{"SEED": "42", "MODE": "a b;$(id)"})
+	if err != nil {
+		t.Fatalf("buildEnvArgs: %v", err)
+	}
+	want := []string{"-e", "MODE=a b;$(id)", "-e", "SEED=42"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+
+	rejected := map[string]map[string]string{
+		"not allowlisted": {"PATH": "/tmp"},
+		"invalid name":    {"1MODE": "x"},
+		"too many":        {"MODE": "x", "SEED": "y", "OTHER": "z"},
+		"value too long":  {"MODE": strings.Repeat("x", 17)},
+		"null byte":       {"MODE": "a\x00b"},
+	}
+	for name, env := range rejected {
+		if _, err := buildEnvArgs(env); err == nil {
+			t.Errorf("%s: %v was accepted", name, env)
+		}
+	}
+}
+
+func TestExecuteEnvReachesProgram(t *testing.T) {
+	useLocalSandbox(t, func(c *models.Config) {
+		c.AllowedEnvVars = []string{"GREETING"}
+	}, "python3")
+
+	result := executeCodeWithContext(context.Background(), models.ExecuteRequest{
+		Language: "python",
+		Code:     "import os\nprint(os.environ.get('GREETING', 'missing'))",
+		Env:      map[string]string{"GREETING": "hello world"},
+	})
+	if result.Error != nil {
+		t.Fatalf("execution failed: %v", result.Error)
+	}
+	if strings.TrimSpace(result.Output) != "hello world" {
+		t.Errorf("output = %q, want the GREETING value", result.Output)
+	}
+
+	result = executeCodeWithContext(context.Background(), models.ExecuteRequest{
+		Language: "python",
+		Code:     "print('unreachable')",
+		Env:      map[string]string{"PATH": "/tmp"},
+	})
+	if result.Error == nil {
+		t.Error("a variable outside the allowlist was accepted")
+	}
+}
+
+func TestRedactEnvArgs(t *testing.T) {
+	args := []string{"run", "--rm", "-e", "TOKEN=s3cret", "-e", "EMPTY=", "-v", "/tmp/x:/code", "img", "sh", "-c", "echo -e A=B"}
+	want := []string{"run", "--rm", "-e", "TOKEN=<redacted>", "-e", "EMPTY=<redacted>", "-v", "/tmp/x:/code", "img", "sh", "-c", "echo -e A=B"}
+
+	if got := redactEnvArgs(args); !reflect.DeepEqual(got, want) {
+		t.Errorf("redactEnvArgs() = %q, want %q", got, want)
+	}
+	if args[3] != "TOKEN=s3cret" {
+		t.Errorf("redactEnvArgs changed its argument: %q", args)
+	}
+}
@@ -2,15 +2,16 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"online-compiler/models"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,11 +24,14 @@ type ExecutionStats struct {
 	Success      bool
 	ErrorMessage string
 	RequestID    string
+	TenantID     string // Set when a trusted auth gateway identified the caller
+	UserID       string
 }
 
 // ExecutionRequest represents a code execution request
 type ExecutionRequest struct {
 	ID       string
+	Ctx      context.Context // Ends when no caller is waiting for the result any more
 	Request  models.ExecuteRequest
 	Response chan ExecutionResult
 	Timeout  time.Duration
@@ -35,64 +39,258 @@ type ExecutionRequest struct {
 
 // ExecutionResult represents the result of code execution
 type ExecutionResult struct {
-	Output string
-	Error  error
+	Output        string // Program stdout
+	CompileOutput string // Compiler output, empty for interpreted languages
+	Stderr        string // Program stderr
+	Stats         ContainerStats
+	Profile       *Profile // Set when profiling was requested and available
+	BinarySize    int64    // Size of the compiled artifacts, 0 for interpreted languages
+	Image         string   // Sandbox image the program ran on
+	Error         error
 }
 
 // ContainerStats represents the resource usage of a container
 type ContainerStats struct {
-	MemoryUsed int64 `json:"memory_used_kb"`
+	MemoryUsed         int64 `json:"memory_used_kb"` // Peak memory in KB
+	MemoryPeakBytes    int64 `json:"memory_peak_bytes"`
+	MemoryCurrentBytes int64 `json:"memory_current_bytes"`
 }
 
 var (
-	statsChan   = make(chan ExecutionStats, 1000)  // Buffer for stats
-	requestChan = make(chan ExecutionRequest, 100) // Buffer for requests
-	workerCount = 10                               // Number of concurrent workers
-	workerWg    sync.WaitGroup
-
-	// Rate limiting
-	rateLimiter    = make(chan struct{}, 20) // Allow 20 concurrent requests
-	requestTimeout = 30 * time.Second        // Default timeout for requests
+	// Runtime configuration
+	config = models.LoadConfig()
+
+	statsChan    = make(chan ExecutionStats, 1000) // Buffer for stats
+	droppedStats atomic.Int64                      // Stats discarded because the buffer was full
+	statsDone    = make(chan struct{})             // Closed once the collector has drained statsChan
+
+	requestChan  = make(chan ExecutionRequest, config.MaxQueueSize) // Buffer for requests
+	workerCount  int                                                // Number of concurrent workers, set by StartWorkers
+	workerWg     sync.WaitGroup
+	busyWorkers  atomic.Int64 // Workers currently handling a request
+	processed    atomic.Int64 // Requests the workers have finished since startup
+	startWorkers sync.Once
+
+	// Guards sends on requestChan against it being closed during shutdown,
+	// and the queues against being replaced once workers read from them
+	queueMu        sync.RWMutex
+	queueClosed    bool
+	workersStarted bool
+
+	// Container concurrency. Each worker runs one container at a time, and
+	// batch executions run outside the worker pool, so this semaphore is the
+	// single bound on simultaneously running containers across both paths.
+	// Concurrent single executions are therefore limited to
+	// min(MaxWorkers, MaxContainers).
+	containerSlots = make(chan struct{}, config.MaxContainers)
+	requestTimeout = 30 * time.Second // Default timeout for requests
+
+	// Destination for completed execution stats, guarded by resultStoreMu
+	resultStoreMu sync.RWMutex
+	resultStore   ResultStore = NewMemoryStore(config.MaxStoredResults)
 )
 
+// SetResultStore sets the store that execution stats are written to
+func SetResultStore(store ResultStore) {
+	resultStoreMu.Lock()
+	defer resultStoreMu.Unlock()
+	resultStore = store
+}
+
+// currentResultStore returns the store set by SetResultStore
+func currentResultStore() ResultStore {
+	resultStoreMu.RLock()
+	defer resultStoreMu.RUnlock()
+	return resultStore
+}
+
+// Configure sets the configuration used by the runner and sizes the
+// container and compile semaphores from it. The request and job queues are
+// sized too until the workers start, after which they keep their size. It
+// must not be called while executions are running.
+func Configure(c *models.Config) {
+	config = c
+	containerSlots = make(chan struct{}, c.MaxContainers)
+	compileSlots = newCompileSlots(c.MaxConcurrentCompiles)
+
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	if !workersStarted && !queueClosed {
+		requestChan = make(chan ExecutionRequest, c.MaxQueueSize)
+		jobQueue = make(chan jobTask, c.MaxQueueSize)
+	}
+}
+
 func init() {
 	// Start stats collector
 	go collectStats()
+}
+
+// StartWorkers starts the worker pool, with one job worker per execution
+// worker to run asynchronous jobs. It is called once the sandbox has been
+// prepared, so workers never pick up executions while the sandbox is down;
+// requests submitted before then wait in the queue. Later calls do nothing.
+func StartWorkers() {
+	startWorkers.Do(func() {
+		queueMu.Lock()
+		workersStarted = true
+		queueMu.Unlock()
+
+		workerCount = config.MaxWorkers
+		for i := 0; i < workerCount; i++ {
+			workerWg.Add(1)
+			go worker()
+			workerWg.Add(1)
+			go jobWorker()
+		}
+	})
+}
+
+// Shutdown stops accepting executions, lets queued executions and jobs finish, and
+// drains buffered stats into the result store before returning. It must be
+// called after the HTTP server has stopped handing requests to the runner.
+func Shutdown(ctx context.Context) error {
+	queueMu.Lock()
+	if !queueClosed {
+		queueClosed = true
+		close(requestChan)
+		close(jobQueue)
+	}
+	queueMu.Unlock()
+
+	// Wait for workers to finish, then for the collector to drain their stats
+	workersDone := make(chan struct{})
+	go func() {
+		workerWg.Wait()
+		stopWarmPools()
+		close(statsChan)
+		close(workersDone)
+	}()
+
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for workers: %w", ctx.Err())
+	}
+	select {
+	case <-statsDone:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out flushing stats: %w", ctx.Err())
+	}
 
-	// Start worker pool
-	for i := 0; i < workerCount; i++ {
-		workerWg.Add(1)
-		go worker()
+	// Release the result store once everything has been written
+	if closer, ok := currentResultStore().(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("failed to close result store: %w", err)
+		}
 	}
+	return nil
 }
 
 func worker() {
 	defer workerWg.Done()
 	for req := range requestChan {
-		// Create a context with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), req.Timeout)
+		busyWorkers.Add(1)
+
+		// Bound the run by its timeout, and stop it, queued or running,
+		// once its callers have gone away
+		ctx, cancel := context.WithTimeout(req.Ctx, req.Timeout)
+
+		// Try to acquire a container slot
+		var result ExecutionResult
+		if err := acquireContainerSlot(ctx); err != nil {
+			result = ExecutionResult{Error: err}
+		} else {
+			result = executeCodeWithContext(ctx, req.Request)
+			releaseContainerSlot()
+		}
+		cancel()
+		processed.Add(1)
+		busyWorkers.Add(-1)
+
+		// Respond last, so the caller sees the slot and the worker freed
+		req.Response <- result
+	}
+}
 
-		// Try to acquire rate limiter
+// QueueMetrics describes worker pool load for autoscaling decisions
+type QueueMetrics struct {
+	QueueDepth    int     `json:"queue_depth"`
+	QueueCapacity int     `json:"queue_capacity"`
+	BusyWorkers   int64   `json:"busy_workers"`
+	TotalWorkers  int     `json:"total_workers"`
+	Processed     int64   `json:"processed"`          // Single executions the workers have finished since startup
+	Admitted      int64   `json:"admitted"`           // Executions queued, waiting for a container or running
+	AdmissionCap  int64   `json:"admission_capacity"` // Admitted executions beyond which requests get 429
+	Utilization   float64 `json:"utilization"`        // Busy workers divided by total workers
+}
+
+// GetQueueMetrics returns the current worker pool load. It reads only
+// channel lengths and atomics, so it never contends with executions.
+func GetQueueMetrics() QueueMetrics {
+	metrics := QueueMetrics{
+		QueueDepth:    len(requestChan),
+		QueueCapacity: cap(requestChan),
+		BusyWorkers:   busyWorkers.Load(),
+		TotalWorkers:  workerCount,
+		Processed:     processed.Load(),
+		Admitted:      admitted.Load(),
+		AdmissionCap:  admissionCapacity(),
+	}
+	if workerCount > 0 {
+		metrics.Utilization = float64(metrics.BusyWorkers) / float64(workerCount)
+	}
+	return metrics
+}
+
+// acquireContainerSlot blocks until a container may be started or ctx ends.
+// A ctx that has already ended never takes a slot.
+func acquireContainerSlot(ctx context.Context) error {
+	if ctx.Err() == nil {
 		select {
-		case rateLimiter <- struct{}{}:
-			// Got rate limit token
-			output, err := executeCodeWithContext(ctx, req.Request)
-			req.Response <- ExecutionResult{
-				Output: output,
-				Error:  err,
-			}
-			<-rateLimiter // Release rate limit token
+		case containerSlots <- struct{}{}:
+			return nil
 		case <-ctx.Done():
-			// Context timed out or was cancelled
-			req.Response <- ExecutionResult{
-				Error: fmt.Errorf("request timed out or rate limit exceeded"),
-			}
 		}
-		cancel()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("request timed out waiting for a free container slot")
+	}
+	return contextError(ctx)
+}
+
+// releaseContainerSlot frees a slot taken by acquireContainerSlot
+func releaseContainerSlot() {
+	<-containerSlots
+}
+
+// emitStats hands stats to the collector without blocking. If the collector
+// has fallen behind and the buffer is full, the stats are dropped and counted
+// so that slow logging or storage never stalls an execution.
+func emitStats(stats ExecutionStats) {
+	select {
+	case statsChan <- stats:
+	default:
+		droppedStats.Add(1)
+	}
+}
+
+// StatsSummary reports the state of the stats pipeline
+type StatsSummary struct {
+	Dropped int64 `json:"dropped_stats"`
+	Pending int   `json:"pending_stats"`
+}
+
+// GetStatsSummary returns the current stats pipeline counters
+func GetStatsSummary() StatsSummary {
+	return StatsSummary{
+		Dropped: droppedStats.Load(),
+		Pending: len(statsChan),
 	}
 }
 
 func collectStats() {
+	defer close(statsDone)
 	for stats := range statsChan {
 		log.Printf("[STATS] Request completed - ID: %s, Language: %s, Duration: %v, Success: %v, Error: %s",
 			stats.RequestID,
@@ -100,49 +298,98 @@ func collectStats() {
 			stats.EndTime.Sub(stats.StartTime),
 			stats.Success,
 			stats.ErrorMessage)
+		recordExecution(stats)
+		if err := currentResultStore().Save(stats); err != nil {
+			log.Printf("[ERROR] Failed to store stats for %s: %v", stats.RequestID, err)
+		}
 	}
 }
 
-func getLanguageSpec(language string) (string, string) {
-	switch language {
-	case "python":
-		return "main.py", "echo -e \"$INPUT\" | python3 /code/main.py"
-	case "java":
-		return "Main.java", "javac /code/Main.java && echo -e \"$INPUT\" | java -cp /code Main"
-	case "cpp":
-		return "main.cpp", "g++ /code/main.cpp -o /code/a.out && echo -e \"$INPUT\" | /code/a.out"
-	case "c":
-		return "main.c", "gcc /code/main.c -o /code/a.out && echo -e \"$INPUT\" | /code/a.out"
-	case "javascript":
-		return "main.js", "echo -e \"$INPUT\" | node /code/main.js"
-	case "go":
-		return "main.go", "echo -e \"$INPUT\" | go run /code/main.go"
-	default:
-		return "", ""
+// execSeq disambiguates executions started in the same nanosecond
+var execSeq atomic.Int64
+
+// newExecID returns a unique ID for an execution directory and container
+func newExecID() string {
+	return fmt.Sprintf("%d_%d", time.Now().UnixNano(), execSeq.Add(1))
+}
+
+// ErrSandboxExists is returned when an execution directory is already in use
+var ErrSandboxExists = errors.New("execution directory already exists")
+
+// createExecDir creates execDir, failing if it already exists rather than
+// reusing it, so two executions never write into the same sandbox. IDs are
+// unique within a process; a conflict means another server shares the
+// sandbox directory.
+func createExecDir(execDir string) error {
+	if err := os.MkdirAll(filepath.Dir(execDir), 0777); err != nil {
+		return err
+	}
+	if err := os.Mkdir(execDir, 0777); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%w: %s", ErrSandboxExists, execDir)
+		}
+		return err
+	}
+	return nil
+}
+
+// singleRunScript builds the in-container command for a single execution.
+// Compiler output, program stdout and program stderr are kept apart: stdout
+// is the container's output while the other two are written to files.
+// With profile set, only the run phase is measured.
+func singleRunScript(lang Language, profile bool) string {
+	script := ""
+	if lang.Compile != "" {
+		script = compileStep(lang, "/code/compile_output.txt") + "; [ $compile_status -eq 0 ] || exit $compile_status; "
+	}
+	run := lang.Run
+	if profile {
+		run = withProfiler(run)
+	}
+	return script + "{ " + run + "; } < /code/input.txt 2> /code/run_stderr.txt"
+}
+
+// readOutputFile returns up to MaxOutputBytes of a file written inside the
+// sandbox, or "" if it is missing or not a regular file
+func readOutputFile(path string) string {
+	limit := int64(config.MaxOutputBytes)
+	if limit <= 0 {
+		limit = int64(config.MaxBatchOutputSize)
+	}
+	data, err := readSandboxFile(path, limit)
+	if err != nil {
+		return ""
 	}
+	return string(data)
 }
 
-func executeCodeWithContext(ctx context.Context, req models.ExecuteRequest) (string, error) {
+func executeCodeWithContext(ctx context.Context, req models.ExecuteRequest) ExecutionResult {
 	stats := ExecutionStats{
 		StartTime: time.Now(),
 		Language:  req.Language,
-		CodeSize:  len(req.Code),
-		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+		CodeSize:  SourceSize(req.Code, req.Files),
+		RequestID: newExecID(),
+		TenantID:  req.TenantID,
+		UserID:    req.UserID,
 	}
 
 	// Validate language
-	codeFile, runCmd := getLanguageSpec(req.Language)
-	if codeFile == "" {
-		return "", fmt.Errorf("unsupported language: %s", req.Language)
+	lang, ok := languages[req.Language]
+	if !ok {
+		return ExecutionResult{Error: fmt.Errorf("%w: %s", ErrUnsupportedLanguage, req.Language)}
+	}
+	image, err := ResolveImage(req.Language, req.Version)
+	if err != nil {
+		return ExecutionResult{Error: err}
 	}
 
-	// Check if Docker is available
-	if err := checkDockerAvailability(); err != nil {
+	// Check that the sandbox can run code
+	if err := sandbox.Available(); err != nil {
 		stats.Success = false
-		stats.ErrorMessage = fmt.Sprintf("Docker not available: %v", err)
+		stats.ErrorMessage = fmt.Sprintf("sandbox not available: %v", err)
 		stats.EndTime = time.Now()
-		statsChan <- stats
-		return "", fmt.Errorf("Docker not available: %w", err)
+		emitStats(stats)
+		return ExecutionResult{Error: fmt.Errorf("sandbox not available: %w", err)}
 	}
 
 	// Create unique directory for this execution
@@ -155,99 +402,192 @@ func executeCodeWithContext(ctx context.Context, req models.ExecuteRequest) (str
 		stats.Success = false
 		stats.ErrorMessage = fmt.Sprintf("failed to get absolute path: %v", err)
 		stats.EndTime = time.Now()
-		statsChan <- stats
-		return "", fmt.Errorf("failed to get absolute path: %w", err)
+		emitStats(stats)
+		return ExecutionResult{Error: fmt.Errorf("failed to get absolute path: %w", err)}
 	}
 
 	// Create execution directory
-	if err := os.MkdirAll(execDir, 0777); err != nil {
+	if err := createExecDir(execDir); err != nil {
 		stats.Success = false
 		stats.ErrorMessage = fmt.Sprintf("failed to create execution directory: %v", err)
 		stats.EndTime = time.Now()
-		statsChan <- stats
-		return "", fmt.Errorf("failed to create execution directory: %w", err)
+		emitStats(stats)
+		return ExecutionResult{Error: fmt.Errorf("failed to create execution directory: %w", err)}
 	}
 
 	// Clean up execution directory when done
 	defer os.RemoveAll(execDir)
 
 	log.Printf("[INFO] Processing request - ID: %s, Language: %s", execID, req.Language)
+	ctx, done := trackExecution(ctx, ActiveExecution{
+		ID:        execID,
+		Kind:      "single",
+		Language:  req.Language,
+		Container: fmt.Sprintf("compiler_%s", execID),
+		StartTime: stats.StartTime,
+	})
+	defer done()
 
-	filePath := filepath.Join(execDir, codeFile)
-
-	// Write code to file in the unique directory
-	if err := os.WriteFile(filePath, []byte(req.Code), 0644); err != nil {
+	// Write code and any further source files to the unique directory
+	if err := writeSources(execDir, lang, req.Code, req.Files); err != nil {
 		stats.Success = false
-		stats.ErrorMessage = fmt.Sprintf("failed to write code file: %v", err)
+		stats.ErrorMessage = err.Error()
 		stats.EndTime = time.Now()
-		statsChan <- stats
-		return "", fmt.Errorf("failed to write code file: %w", err)
+		emitStats(stats)
+		return ExecutionResult{Error: err}
 	}
 
-	// Create container name
-	containerName := fmt.Sprintf("compiler_%s", execID)
+	// Run a precompiled artifact as is, or reuse prewarmed build artifacts instead of compiling again
+	if req.Artifact != nil {
+		if lang, err = writeArtifact(lang, req.Artifact, execDir); err != nil {
+			stats.Success = false
+			stats.ErrorMessage = err.Error()
+			stats.EndTime = time.Now()
+			emitStats(stats)
+			return ExecutionResult{Error: err}
+		}
+	} else if !req.NoCache && req.Version == "" && len(req.Files) == 0 && restoreCompiled(lang, req.Code, execDir) {
+		lang.Compile = ""
+	}
 
-	// Create a channel to signal when the command is done
-	done := make(chan error, 1)
-	var output []byte
-	var cmdErr error
-
-	// Run the code inside the container with resource limits
-	cmd := exec.CommandContext(ctx, "docker", "run", "--rm",
-		"--name", containerName,
-		"--memory=512m",
-		"--cpus=1",
-		"--network=none",
-		"--pids-limit=100",
-		"--ulimit", "nproc=100",
-		"--stop-timeout=10",
-		"-e", fmt.Sprintf("INPUT=%s", req.Input),
-		"-v", absExecDir+":/code",
-		"compiler-image",
-		"sh", "-c", runCmd)
-
-	log.Printf("[DEBUG] Running Docker command: %s", strings.Join(cmd.Args, " "))
-
-	// Run the command in a goroutine
-	go func() {
-		output, cmdErr = cmd.CombinedOutput()
-		done <- cmdErr
-	}()
+	// Write stdin to a file so large inputs never pass through the environment
+	if err := os.WriteFile(filepath.Join(execDir, "input.txt"), []byte(req.Input), 0644); err != nil {
+		stats.Success = false
+		stats.ErrorMessage = fmt.Sprintf("failed to write input file: %v", err)
+		stats.EndTime = time.Now()
+		emitStats(stats)
+		return ExecutionResult{Error: fmt.Errorf("failed to write input file: %w", err)}
+	}
 
-	// Wait for either the command to finish or the context to timeout
-	select {
-	case err := <-done:
-		// Command completed normally
+	// Validate per-request environment variables
+	if err := ValidateEnv(req.Env); err != nil {
+		stats.Success = false
+		stats.ErrorMessage = err.Error()
 		stats.EndTime = time.Now()
+		emitStats(stats)
+		return ExecutionResult{Error: err}
+	}
+
+	// Under a compile limit, compile first so only that phase holds a compile slot
+	var output []byte
+	if splitCompile(lang) {
+		if _, err = compilePhase(ctx, lang, execID, absExecDir, image, req.Env); err == nil {
+			lang.Compile = ""
+		}
+	}
+	if err == nil {
+		output, err = sandbox.Run(ctx, RunSpec{
+			Name:          fmt.Sprintf("compiler_%s", execID),
+			Language:      req.Language,
+			Dir:           absExecDir,
+			Script:        withMemoryProbe(singleRunScript(lang, req.Profile)),
+			Env:           req.Env,
+			MemoryLimitMB: config.MemoryLimitFor(req.Language),
+			CPUs:          config.CPULimitFor(req.Language),
+			PidsLimit:     config.PidsLimitFor(req.Language),
+			StopTimeout:   10,
+			Image:         image,
+			StackSizeMB:   StackLimit(req.StackSizeMB),
+			OpenFiles:     config.MaxOpenFiles,
+		})
+	}
+	stats.EndTime = time.Now()
+
+	if ctx.Err() == nil {
+		result := ExecutionResult{
+			Output:        string(output),
+			CompileOutput: readOutputFile(filepath.Join(execDir, "compile_output.txt")),
+			Stderr:        readOutputFile(filepath.Join(execDir, "run_stderr.txt")),
+			Stats:         readMemoryStats(execDir),
+			BinarySize:    binarySize(languages[req.Language], execDir),
+			Image:         image,
+		}
+		if req.Profile {
+			result.Profile = readProfile(filepath.Join(execDir, "profile.txt"))
+		}
+		if errors.Is(err, ErrOutputLimitExceeded) {
+			stats.Success = false
+			stats.ErrorMessage = err.Error()
+			emitStats(stats)
+			result.Output += outputTruncatedNotice()
+			result.Error = err
+			return result
+		}
 		if err != nil {
 			stats.Success = false
 			stats.ErrorMessage = fmt.Sprintf("execution failed: %v", err)
-			statsChan <- stats
-			return string(output), fmt.Errorf("execution failed: %w\nOutput: %s", err, string(output))
+			emitStats(stats)
+			err = describeCrash(err, StackLimit(req.StackSizeMB))
+			if exhaustedFiles(result.Stderr) {
+				err = fmt.Errorf("%s: %w", openFilesMessage(), err)
+			}
+			result.Error = fmt.Errorf("execution failed: %w\nOutput: %s", err, result.CompileOutput+result.Output+result.Stderr)
+			return result
 		}
 		stats.Success = true
-		statsChan <- stats
-		return string(output), nil
-	case <-ctx.Done():
-		// Context timed out - force kill the container
-		killCmd := exec.Command("docker", "kill", containerName)
-		if err := killCmd.Run(); err != nil {
-			log.Printf("[ERROR] Failed to kill container %s: %v", containerName, err)
-		}
-		// Force remove the container even if kill failed
-		rmCmd := exec.Command("docker", "rm", "-f", containerName)
-		if err := rmCmd.Run(); err != nil {
-			log.Printf("[ERROR] Failed to remove container %s: %v", containerName, err)
-		}
-		stats.EndTime = time.Now()
-		stats.Success = false
-		stats.ErrorMessage = "execution timed out (possible infinite loop detected)"
-		statsChan <- stats
-		return "Execution timed out. Your code may contain an infinite loop or is taking too long to execute.", ctx.Err()
+		emitStats(stats)
+		return result
+	}
+
+	// The context ended and the sandbox stopped the run. Only a deadline is
+	// the program's fault; a cancellation comes from the server or client.
+	stats.Success = false
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		stats.ErrorMessage = "request cancelled"
+		emitStats(stats)
+		return ExecutionResult{Error: contextError(ctx)}
+	}
+	stats.ErrorMessage = "execution timed out (possible infinite loop detected)"
+	emitStats(stats)
+	return ExecutionResult{
+		Output: "Execution timed out. Your code may contain an infinite loop or is taking too long to execute.",
+		Error:  contextError(ctx),
 	}
 }
 
-func ExecuteInDocker(ctx context.Context, req models.ExecuteRequest) (string, error) {
+// contextError describes why ctx ended, telling a timeout apart from a
+// cancellation. The context error is wrapped so callers can use errors.Is.
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("execution timed out: %w", ctx.Err())
+	}
+	return fmt.Errorf("request cancelled: %w", ctx.Err())
+}
+
+// ExecuteInDocker runs req in the worker pool and returns its outputs and memory usage
+func ExecuteInDocker(ctx context.Context, req models.ExecuteRequest) (ExecutionResult, error) {
+	if !ImageReady() {
+		return ExecutionResult{}, ErrWarmingUp
+	}
+	if ctx.Err() != nil {
+		return ExecutionResult{}, contextError(ctx)
+	}
+
+	if !LanguageEnabled(req.Language) && LanguageSupported(req.Language) {
+		return ExecutionResult{}, fmt.Errorf("%w: %s", ErrLanguageDisabled, req.Language)
+	}
+	if image, err := ResolveImage(req.Language, req.Version); err != nil {
+		return ExecutionResult{}, err
+	} else if !imageAvailable(image) {
+		return ExecutionResult{}, ErrWarmingUp
+	}
+
+	// Identical concurrent requests share a single execution, unless the
+	// caller asked for a fresh build and run
+	if req.NoCache {
+		result := submitExecution(ctx, req)
+		return result, result.Error
+	}
+	result := inflight.Do(ctx, dedupKey(req), func(ctx context.Context) ExecutionResult {
+		return submitExecution(ctx, req)
+	})
+	return result, result.Error
+}
+
+// submitExecution queues req on the worker pool and waits for its result.
+// The execution is cancelled when ctx ends, so a caller that goes away
+// frees its queue entry and container.
+func submitExecution(ctx context.Context, req models.ExecuteRequest) ExecutionResult {
 	// Create response channel
 	responseChan := make(chan ExecutionResult, 1)
 
@@ -257,68 +597,47 @@ func ExecuteInDocker(ctx context.Context, req models.ExecuteRequest) (string, er
 	// Create execution request with timeout
 	execReq := ExecutionRequest{
 		ID:       requestID,
+		Ctx:      ctx,
 		Request:  req,
 		Response: responseChan,
-		Timeout:  requestTimeout,
+		Timeout:  ScaleTimeLimit(req.Language, requestTimeout) + CompileTimeout(req.Language),
 	}
 
-	// Try to send request to worker pool with timeout
+	// Refuse immediately rather than wait behind a full set of containers
+	release, err := admit()
+	if err != nil {
+		return ExecutionResult{Error: err}
+	}
+	defer release()
+
+	// Try to send request to worker pool
+	queueMu.RLock()
+	if queueClosed {
+		queueMu.RUnlock()
+		return ExecutionResult{Error: fmt.Errorf("server is shutting down")}
+	}
 	select {
 	case requestChan <- execReq:
 		// Request accepted
-	case <-ctx.Done():
-		return "", fmt.Errorf("request cancelled: %w", ctx.Err())
 	default:
 		// Queue is full
-		return "", fmt.Errorf("server is busy, please try again later")
+		queueMu.RUnlock()
+		return ExecutionResult{Error: ErrServerBusy}
 	}
+	queueMu.RUnlock()
 
-	// Wait for response with context timeout
-	select {
-	case result := <-responseChan:
-		// Get memory usage
-		memoryUsage, err := GetContainerStats(ctx, req)
-		if err != nil {
-			return result.Output, result.Error
-		}
-		return fmt.Sprintf("%s\nMemory Used: %d KB", result.Output, memoryUsage.MemoryUsed), result.Error
-	case <-ctx.Done():
-		return "", fmt.Errorf("request cancelled: %w", ctx.Err())
-	}
+	// Workers always respond once the request's own timeout expires or ctx ends
+	return <-responseChan
 }
 
-// GetContainerStats retrieves the resource usage statistics for a container
-func GetContainerStats(ctx context.Context, req models.ExecuteRequest) (ContainerStats, error) {
-	// Get the container ID from the execution
-	containerID := fmt.Sprintf("compiler_%d", time.Now().UnixNano())
-
-	// Get container stats using docker stats
-	cmd := exec.CommandContext(ctx, "docker", "stats", containerID, "--no-stream", "--format", "{{.MemUsage}}")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return ContainerStats{}, fmt.Errorf("failed to get container stats: %w", err)
-	}
-
-	// Parse memory usage (format: "123.45MB / 512MB")
-	memParts := strings.Split(strings.TrimSpace(string(output)), " / ")
-	if len(memParts) != 2 {
-		return ContainerStats{}, fmt.Errorf("invalid memory format")
-	}
-	memUsed := strings.TrimSpace(memParts[0])
-	memUsed = strings.TrimSuffix(memUsed, "MB")
-	memUsedKB, err := strconv.ParseFloat(memUsed, 64)
-	if err != nil {
-		return ContainerStats{}, fmt.Errorf("failed to parse memory usage: %w", err)
-	}
-
-	return ContainerStats{
-		MemoryUsed: int64(memUsedKB * 1024), // Convert MB to KB
-	}, nil
+// StatsEnabled reports whether memory usage should be reported for a request
+func StatsEnabled(noStats bool) bool {
+	return config.CollectContainerStats && !noStats
 }
 
-// checkDockerAvailability verifies that Docker is running and accessible
-func checkDockerAvailability() error {
-	cmd := exec.Command("docker", "info")
+// CheckDockerAvailability verifies that Docker is running and accessible
+func CheckDockerAvailability() error {
+	cmd := dockerCommand("info")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("Docker is not running or not accessible: %w", err)
 	}
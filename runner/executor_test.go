@@ -0,0 +1,447 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"online-compiler/models"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSandbox runs nothing. Each run counts as running until release is
+// closed or its context ends, and the peak number of simultaneous runs is
+// recorded. started receives every spec as its run begins.
+type fakeSandbox struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	started chan RunSpec
+	release chan struct{}
+}
+
+func (s *fakeSandbox) Prepare() error   { return nil }
+func (s *fakeSandbox) Available() error { return nil }
+
+func (s *fakeSandbox) Run(ctx context.Context, spec RunSpec) ([]byte, error) {
+	s.mu.Lock()
+	s.running++
+	if s.running > s.peak {
+		s.peak = s.running
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+	}()
+
+	select {
+	case s.started <- spec:
+	default:
+	}
+	select {
+	case <-s.release:
+		return []byte("ok\n"), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// peakRuns returns the most runs that were in progress at once
+func (s *fakeSandbox) peakRuns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peak
+}
+
+// runningRuns returns the number of runs in progress
+func (s *fakeSandbox) runningRuns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// useFakeSandbox runs the test's executions on a fakeSandbox under a copy of
+// the configuration changed by edit, with the worker pool started
+func useFakeSandbox(t *testing.T, edit func(c *models.Config)) *fakeSandbox {
+	useConfig(t, func(c *models.Config) {
+		c.WarmPoolSize = 0
+		if edit != nil {
+			edit(c)
+		}
+	})
+	chdirTemp(t)
+
+	fake := &fakeSandbox{started: make(chan RunSpec, 100), release: make(chan struct{})}
+	previous := sandbox
+	SetSandbox(fake)
+	t.Cleanup(func() { SetSandbox(previous) })
+	imageReady.Store(true)
+	StartWorkers()
+	return fake
+}
+
+// waitStarted waits until n runs have started on fake
+func waitStarted(t *testing.T, fake *fakeSandbox, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-fake.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d runs started", i, n)
+		}
+	}
+}
+
+func TestContainerLimit(t *testing.T) {
+	fake := useFakeSandbox(t, func(c *models.Config) { c.MaxContainers = 2 })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := ExecuteInDocker(context.Background(), models.ExecuteRequest{
+				Language: "python",
+				Code:     fmt.Sprintf("print(%d)", i),
+			}); err != nil {
+				t.Errorf("execution %d: %v", i, err)
+			}
+		}(i)
+	}
+
+	// Two runs start and the rest wait for a container slot
+	waitStarted(t, fake, 2)
+	time.Sleep(50 * time.Millisecond)
+	if peak := fake.peakRuns(); peak != 2 {
+		t.Errorf("%d runs started at once, want the 2 allowed containers", peak)
+	}
+	close(fake.release)
+	wg.Wait()
+
+	if peak := fake.peakRuns(); peak > 2 {
+		t.Errorf("%d containers ran at once, want at most 2", peak)
+	}
+}
+
+func TestConfigureSizesAdmission(t *testing.T) {
+	useConfig(t, func(c *models.Config) {
+		c.MaxContainers = 3
+		c.MaxConcurrentCompiles = 2
+	})
+
+	if got := cap(containerSlots); got != 3 {
+		t.Errorf("container semaphore holds %d slots, want 3", got)
+	}
+	if got := cap(compileSlots); got != 2 {
+		t.Errorf("compile semaphore holds %d slots, want 2", got)
+	}
+	if got, want := admissionCapacity(), int64(3+cap(requestChan)); got != want {
+		t.Errorf("admission capacity = %d, want %d", got, want)
+	}
+}
+
+// waitStored waits until the result store holds n results and returns them
+func waitStored(t *testing.T, store ResultStore, n int) []ExecutionStats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		list, err := store.List(n+1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) >= n {
+			return list
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the store holds %d results, want %d", len(list), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// useResultStore makes the runner write stats to a fresh memory store until the test ends
+func useResultStore(t *testing.T) *MemoryStore {
+	store := NewMemoryStore(0)
+	previous := currentResultStore()
+	SetResultStore(store)
+	t.Cleanup(func() { SetResultStore(previous) })
+	return store
+}
+
+func TestStatsRecordIdentity(t *testing.T) {
+	fake := useFakeSandbox(t, nil)
+	close(fake.release)
+	store := useResultStore(t)
+
+	result := executeCodeWithContext(context.Background(), models.ExecuteRequest{
+		Language: "python",
+		Code:     "print(1)",
+		TenantID: "acme",
+		UserID:   "alice",
+	})
+	if result.Error != nil {
+		t.Fatalf("execution failed: %v", result.Error)
+	}
+
+	stats := waitStored(t, store, 1)[0]
+	if stats.TenantID != "acme" || stats.UserID != "alice" {
+		t.Errorf("stored stats = %+v, want tenant acme and user alice", stats)
+	}
+}
+
+func TestQueueMetricsBusyWorkers(t *testing.T) {
+	fake := useFakeSandbox(t, nil)
+	processed := GetQueueMetrics().Processed
+
+	done := make(chan error)
+	go func() {
+		_, err := ExecuteInDocker(context.Background(), models.ExecuteRequest{Language: "python", Code: "print('busy')"})
+		done <- err
+	}()
+	waitStarted(t, fake, 1)
+
+	metrics := GetQueueMetrics()
+	if metrics.BusyWorkers != 1 {
+		t.Errorf("busy_workers = %d while a run is blocked, want 1", metrics.BusyWorkers)
+	}
+	if metrics.Utilization != 1/float64(metrics.TotalWorkers) {
+		t.Errorf("utilization = %v, want one of %d workers", metrics.Utilization, metrics.TotalWorkers)
+	}
+
+	close(fake.release)
+	if err := <-done; err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+	metrics = GetQueueMetrics()
+	if metrics.BusyWorkers != 0 {
+		t.Errorf("busy_workers = %d after the run, want 0", metrics.BusyWorkers)
+	}
+	if metrics.Processed != processed+1 {
+		t.Errorf("processed = %d, want %d", metrics.Processed, processed+1)
+	}
+}
+
+// closingStore is a MemoryStore that is slow to save and records being closed
+type closingStore struct {
+	*MemoryStore
+	closed atomic.Bool
+}
+
+func (s *closingStore) Save(stats ExecutionStats) error {
+	time.Sleep(time.Millisecond)
+	return s.MemoryStore.Save(stats)
+}
+
+func (s *closingStore) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func TestShutdownFlushesStats(t *testing.T) {
+	if os.Getenv("RUNNER_SHUTDOWN_TEST") == "" {
+		// Shutdown stops the runner for good, so it runs in its own process
+		cmd := exec.Command(os.Args[0], "-test.run=^TestShutdownFlushesStats$")
+		cmd.Env = append(os.Environ(), "RUNNER_SHUTDOWN_TEST=1")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("shutdown test failed: %v\n%s", err, output)
+		}
+		return
+	}
+
+	store := &closingStore{MemoryStore: NewMemoryStore(0)}
+	SetResultStore(store)
+	StartWorkers()
+
+	// The slow store keeps most of these buffered when Shutdown begins
+	const n = 100
+	for i := 0; i < n; i++ {
+		emitStats(ExecutionStats{RequestID: fmt.Sprint(i), Language: "python", StartTime: time.Now()})
+	}
+	if pending := GetStatsSummary().Pending; pending == 0 {
+		t.Log("the collector kept up, so no stats were left buffered")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if list, _ := store.List(2*n, 0); len(list) != n {
+		t.Errorf("the store holds %d results after shutdown, want all %d", len(list), n)
+	}
+	if dropped := GetStatsSummary().Dropped; dropped != 0 {
+		t.Errorf("%d stats were dropped", dropped)
+	}
+	if !store.closed.Load() {
+		t.Error("the result store was not closed after the flush")
+	}
+	if _, err := ExecuteInDocker(context.Background(), models.ExecuteRequest{Language: "python", Code: "print(1)"}); err == nil {
+		t.Error("an execution was accepted after shutdown")
+	}
+}
+
+// blockingStore is a MemoryStore whose Save waits until release is closed
+type blockingStore struct {
+	*MemoryStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Save(stats ExecutionStats) error {
+	select {
+	case s.saving <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return s.MemoryStore.Save(stats)
+}
+
+func TestEmitStatsDropsWhenFull(t *testing.T) {
+	store := &blockingStore{MemoryStore: NewMemoryStore(0), saving: make(chan struct{}, 1), release: make(chan struct{})}
+	previous := currentResultStore()
+	SetResultStore(store)
+	released := false
+	release := func() {
+		if !released {
+			released = true
+			close(store.release)
+		}
+	}
+	t.Cleanup(func() {
+		release()
+		waitStored(t, store.MemoryStore, cap(statsChan)+1)
+		SetResultStore(previous)
+	})
+
+	// Park the collector in Save, then fill the buffer behind it
+	emitStats(ExecutionStats{RequestID: "first", StartTime: time.Now()})
+	select {
+	case <-store.saving:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the collector never saved the first stat")
+	}
+	for i := 0; len(statsChan) < cap(statsChan); i++ {
+		statsChan <- ExecutionStats{RequestID: fmt.Sprint("filler-", i), StartTime: time.Now()}
+	}
+
+	before := droppedStats.Load()
+	done := make(chan struct{})
+	go func() {
+		emitStats(ExecutionStats{RequestID: "overflow", StartTime: time.Now()})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		release()
+		t.Fatal("emitStats blocked on a full stats buffer")
+	}
+	if got := droppedStats.Load() - before; got != 1 {
+		t.Errorf("droppedStats grew by %d, want 1", got)
+	}
+	if summary := GetStatsSummary(); summary.Pending != cap(statsChan) {
+		t.Errorf("pending stats = %d, want %d", summary.Pending, cap(statsChan))
+	}
+}
+
+func TestCreateExecDirConflict(t *testing.T) {
+	execDir := filepath.Join(t.TempDir(), "temp", "exec_1")
+
+	const creators = 8
+	errs := make(chan error, creators)
+	var start sync.WaitGroup
+	start.Add(1)
+	for i := 0; i < creators; i++ {
+		go func() {
+			start.Wait()
+			errs <- createExecDir(execDir)
+		}()
+	}
+	start.Done()
+
+	created := 0
+	for i := 0; i < creators; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrSandboxExists):
+			t.Errorf("createExecDir: %v, want ErrSandboxExists", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d of %d concurrent creators got the directory, want exactly 1", created, creators)
+	}
+
+	// A directory left behind is never reused either
+	if err := createExecDir(execDir); !errors.Is(err, ErrSandboxExists) {
+		t.Errorf("creating an existing directory: %v, want ErrSandboxExists", err)
+	}
+}
+
+func TestCallerCancelStopsExecution(t *testing.T) {
+	fake := useFakeSandbox(t, func(c *models.Config) { c.MaxContainers = 1 })
+
+	execute := func(ctx context.Context, code string) <-chan error {
+		errs := make(chan error, 1)
+		go func() {
+			_, err := ExecuteInDocker(ctx, models.ExecuteRequest{Language: "python", Code: code})
+			errs <- err
+		}()
+		return errs
+	}
+	wantCancelled := func(errs <-chan error, what string) {
+		t.Helper()
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("%s returned %v, want a cancellation", what, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s did not return after its caller went away", what)
+		}
+	}
+
+	// The running execution holds the only container while another waits for it
+	runningCtx, cancelRunning := context.WithCancel(context.Background())
+	defer cancelRunning()
+	running := execute(runningCtx, "print('running')")
+	waitStarted(t, fake, 1)
+	queuedCtx, cancelQueued := context.WithCancel(context.Background())
+	queued := execute(queuedCtx, "print('queued')")
+	waitFor(t, "the second execution to queue", func() bool { return GetQueueMetrics().BusyWorkers == 2 })
+
+	cancelQueued()
+	wantCancelled(queued, "the queued execution")
+	waitFor(t, "the queued execution to leave its worker", func() bool { return GetQueueMetrics().BusyWorkers == 1 })
+
+	cancelRunning()
+	wantCancelled(running, "the running execution")
+	waitFor(t, "the container to stop", func() bool { return fake.runningRuns() == 0 && GetQueueMetrics().BusyWorkers == 0 })
+
+	select {
+	case spec := <-fake.started:
+		t.Errorf("the cancelled queued execution still ran: %s", spec.Name)
+	default:
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after 5 seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
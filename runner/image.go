@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrWarmingUp is returned while the sandbox image is still being pulled
+var ErrWarmingUp = errors.New("backend warming up, please try again shortly")
+
+// imageReady is set once the sandbox image is known to exist locally
+var imageReady atomic.Bool
+
+// ImageReady reports whether the sandbox image is available for executions
+func ImageReady() bool {
+	return imageReady.Load()
+}
+
+// PrepareImage makes sure the sandbox image is available before traffic arrives.
+// A missing image is fatal unless the pull policy is "if-missing", in which case
+// it is pulled in the background and executions fail fast with ErrWarmingUp
+// until the pull completes, so no request ever blocks on a pull.
+// The images pinned to language versions are prepared the same way.
+func PrepareImage() error {
+	ready := func() {
+		imageReady.Store(true)
+		startWarmPools()
+	}
+	if err := prepareImage(config.Image, ready); err != nil {
+		return err
+	}
+	return preparePinnedImages()
+}
+
+// prepareImage calls ready once image exists locally, pulling it in the
+// background if it is missing and the pull policy allows
+func prepareImage(image string, ready func()) error {
+	if err := checkImage(image); err == nil {
+		ready()
+		return nil
+	} else if config.ImagePullPolicy != "if-missing" {
+		return err
+	}
+
+	log.Printf("[INFO] Image %s not found locally, pulling in the background", image)
+	go func() {
+		cmd := dockerCommand("pull", image)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("[ERROR] Failed to pull image %s: %v\nOutput: %s", image, err, strings.TrimSpace(string(output)))
+			return
+		}
+		ready()
+		log.Printf("[INFO] Image %s is ready", image)
+	}()
+	return nil
+}
+
+// CheckImageAvailability verifies that the configured sandbox image exists locally
+func CheckImageAvailability() error {
+	return checkImage(config.Image)
+}
+
+// checkImage verifies that image exists locally
+func checkImage(image string) error {
+	cmd := dockerCommand("image", "inspect", image)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("image %q not found (build it with `docker compose build compiler-image`): %w\nOutput: %s",
+			image, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// ImageDigest returns the ID of the configured sandbox image
+func ImageDigest() (string, error) {
+	cmd := dockerCommand("image", "inspect", "--format", "{{.Id}}", config.Image)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %q: %w", config.Image, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
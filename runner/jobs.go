@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// JobStatus is the state of an asynchronous job
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// ErrJobNotFound is returned when a job does not exist or has expired
+var ErrJobNotFound = errors.New("job not found")
+
+// JobFunc runs a job and returns its result
+type JobFunc func(ctx context.Context) (interface{}, error)
+
+// Job is an execution submitted to run in the background and polled for its result
+type Job struct {
+	ID         string      `json:"job_id"`
+	Status     JobStatus   `json:"status"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"` // Set once finished; the job is removed after this time
+}
+
+// jobTask is a queued job waiting for a job worker
+type jobTask struct {
+	id      string
+	run     JobFunc
+	timeout time.Duration
+}
+
+var (
+	// Jobs waiting for a job worker, guarded by queueMu like requestChan
+	jobQueue = make(chan jobTask, config.MaxQueueSize)
+
+	// jobs holds queued, running and recently finished jobs, keyed by ID
+	jobs = struct {
+		sync.Mutex
+		byID map[string]*Job
+	}{byID: make(map[string]*Job)}
+
+	// jobClock timestamps jobs and decides when they expire
+	jobClock = time.Now
+)
+
+// SubmitJob queues run to execute in the background under the given timeout
+// and returns the queued job. Returns ErrServerBusy when the job queue is full.
+func SubmitJob(timeout time.Duration, run JobFunc) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+	job := &Job{ID: id, Status: JobQueued, CreatedAt: jobClock()}
+
+	jobs.Lock()
+	expireJobs(job.CreatedAt)
+	jobs.byID[id] = job
+	snapshot := *job
+	jobs.Unlock()
+
+	queueMu.RLock()
+	defer queueMu.RUnlock()
+	if queueClosed {
+		removeJob(id)
+		return Job{}, fmt.Errorf("server is shutting down")
+	}
+	select {
+	case jobQueue <- jobTask{id: id, run: run, timeout: timeout}:
+		return snapshot, nil
+	default:
+		removeJob(id)
+		return Job{}, ErrServerBusy
+	}
+}
+
+// GetJob returns the job with the given ID
+func GetJob(id string) (Job, error) {
+	jobs.Lock()
+	defer jobs.Unlock()
+
+	expireJobs(jobClock())
+	job, ok := jobs.byID[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// jobWorker runs queued jobs and records their results
+func jobWorker() {
+	defer workerWg.Done()
+	for task := range jobQueue {
+		started := jobClock()
+		updateJob(task.id, func(job *Job) {
+			job.Status = JobRunning
+			job.StartedAt = &started
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), task.timeout)
+		result, err := task.run(ctx)
+		cancel()
+
+		finished := jobClock()
+		expires := finished.Add(config.JobTTL)
+		updateJob(task.id, func(job *Job) {
+			job.Status, job.Result = JobDone, result
+			if err != nil {
+				job.Status, job.Result = JobFailed, nil
+				job.Error = err.Error()
+			}
+			job.FinishedAt = &finished
+			job.ExpiresAt = &expires
+		})
+		if err != nil {
+			log.Printf("[WARN] Job %s failed after %v: %v", task.id, finished.Sub(started), err)
+		}
+	}
+}
+
+// updateJob applies fn to the job with the given ID, if it still exists
+func updateJob(id string, fn func(job *Job)) {
+	jobs.Lock()
+	defer jobs.Unlock()
+	if job, ok := jobs.byID[id]; ok {
+		fn(job)
+	}
+}
+
+// removeJob forgets the job with the given ID
+func removeJob(id string) {
+	jobs.Lock()
+	delete(jobs.byID, id)
+	jobs.Unlock()
+}
+
+// expireJobs removes finished jobs whose TTL has passed. Callers must hold jobs.
+func expireJobs(now time.Time) {
+	for id, job := range jobs.byID {
+		if job.ExpiresAt != nil && now.After(*job.ExpiresAt) {
+			delete(jobs.byID, id)
+		}
+	}
+}
+
+// newJobID returns a random, hard to guess job ID
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
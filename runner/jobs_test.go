@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// advanceJobClock replaces jobClock with a clock the returned function moves
+// forward, restoring it when the test ends
+func advanceJobClock(t *testing.T) func(d time.Duration) {
+	var skew atomic.Int64
+	jobClock = func() time.Time { return time.Now().Add(time.Duration(skew.Load())) }
+	t.Cleanup(func() { jobClock = time.Now })
+	return func(d time.Duration) { skew.Add(int64(d)) }
+}
+
+// waitForJob polls the job until it has finished
+func waitForJob(t *testing.T, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := GetJob(id)
+		if err != nil {
+			t.Fatalf("GetJob(%s): %v", id, err)
+		}
+		if job.Status == JobDone || job.Status == JobFailed {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestJobRunsToCompletion(t *testing.T) {
+	StartWorkers()
+
+	job, err := SubmitJob(time.Second, func(ctx context.Context) (interface{}, error) {
+		return "graded", nil
+	})
+	if err != nil {
+		t.Fatalf("SubmitJob: %v", err)
+	}
+	if job.Status != JobQueued || job.ID == "" {
+		t.Fatalf("submitted job = %+v, want a queued job with an ID", job)
+	}
+
+	done := waitForJob(t, job.ID)
+	if done.Status != JobDone || done.Result != "graded" || done.Error != "" {
+		t.Errorf("finished job = %+v, want done with result \"graded\"", done)
+	}
+	if done.StartedAt == nil || done.FinishedAt == nil || done.ExpiresAt == nil {
+		t.Errorf("finished job is missing timestamps: %+v", done)
+	}
+}
+
+func TestJobRecordsFailure(t *testing.T) {
+	StartWorkers()
+
+	job, err := SubmitJob(time.Second, func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("compiler exploded")
+	})
+	if err != nil {
+		t.Fatalf("SubmitJob: %v", err)
+	}
+
+	done := waitForJob(t, job.ID)
+	if done.Status != JobFailed || done.Error != "compiler exploded" || done.Result != nil {
+		t.Errorf("failed job = %+v", done)
+	}
+}
+
+func TestJobExpiresAfterTTL(t *testing.T) {
+	StartWorkers()
+	advance := advanceJobClock(t)
+
+	job, err := SubmitJob(time.Second, func(ctx context.Context) (interface{}, error) {
+		return 1, nil
+	})
+	if err != nil {
+		t.Fatalf("SubmitJob: %v", err)
+	}
+	waitForJob(t, job.ID)
+
+	advance(config.JobTTL - time.Second)
+	if _, err := GetJob(job.ID); err != nil {
+		t.Fatalf("job expired before its TTL: %v", err)
+	}
+
+	advance(2 * time.Second)
+	if _, err := GetJob(job.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("GetJob after the TTL returned %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestGetUnknownJob(t *testing.T) {
+	if _, err := GetJob("does-not-exist"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("GetJob of an unknown ID returned %v, want ErrJobNotFound", err)
+	}
+}
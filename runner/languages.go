@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrUnsupportedLanguage is returned for a language missing from the registry
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	// ErrLanguageDisabled is returned for a supported language that has been turned off by configuration
+	ErrLanguageDisabled = errors.New("language temporarily unavailable")
+)
+
+// Language describes a language the runner knows how to execute
+type Language struct {
+	Name     string `json:"name"`
+	FileName string `json:"file_name"`
+	Compile  string `json:"-"` // Compile command, empty for interpreted languages
+	Run      string `json:"-"` // Command that runs the program, reading stdin
+	// Glob patterns, relative to /code, of the files the compile step produces
+	Artifacts []string `json:"-"`
+}
+
+// languages is the registry of supported languages. C and C++ compile every
+// source file under /code and Go runs every file beside main.go, so
+// multi-file programs build without a project file.
+var languages = map[string]Language{
+	"python": {
+		Name:     "python",
+		FileName: "main.py",
+		Run:      "python3 /code/main.py",
+	},
+	"java": {
+		Name:      "java",
+		FileName:  "Main.java",
+		Compile:   "javac /code/Main.java",
+		Run:       "java -cp /code Main",
+		Artifacts: []string{"*.class"},
+	},
+	"cpp": {
+		Name:      "cpp",
+		FileName:  "main.cpp",
+		Compile:   "find /code -name '*.cpp' -exec g++ -o /code/a.out {} +",
+		Run:       "/code/a.out",
+		Artifacts: []string{"a.out"},
+	},
+	"c": {
+		Name:      "c",
+		FileName:  "main.c",
+		Compile:   "find /code -name '*.c' -exec gcc -o /code/a.out {} +",
+		Run:       "/code/a.out",
+		Artifacts: []string{"a.out"},
+	},
+	"javascript": {
+		Name:     "javascript",
+		FileName: "main.js",
+		Run:      "node /code/main.js",
+	},
+	"go": {
+		Name:     "go",
+		FileName: "main.go",
+		Run:      "go run /code/*.go",
+	},
+	"ruby": {
+		Name:     "ruby",
+		FileName: "main.rb",
+		Run:      "ruby /code/main.rb",
+	},
+}
+
+// CompileTimeout returns the compile phase timeout for a language, or 0 if it is not compiled
+func CompileTimeout(name string) time.Duration {
+	if languages[name].Compile == "" {
+		return 0
+	}
+	return config.CompileTimeoutFor(name)
+}
+
+// ScaleTimeLimit applies the language's time limit multiplier to base
+func ScaleTimeLimit(name string, base time.Duration) time.Duration {
+	return time.Duration(float64(base) * config.TimeLimitMultiplier(name))
+}
+
+// compileStep returns a script fragment that compiles lang under its compile
+// timeout, writing compiler output to outFile and the exit status to
+// $compile_status. A timed-out compile is explained in outFile.
+func compileStep(lang Language, outFile string) string {
+	timeout := CompileTimeout(lang.Name)
+	return fmt.Sprintf("timeout %.3fs %s > %s 2>&1; compile_status=$?; "+
+		"if [ $compile_status -eq 124 ]; then echo \"Compilation timed out after %v\" >> %s; fi",
+		timeout.Seconds(), lang.Compile, outFile, timeout, outFile)
+}
+
+// LanguageSupported reports whether the registry knows the language
+func LanguageSupported(name string) bool {
+	_, ok := languages[name]
+	return ok
+}
+
+// LanguageEnabled reports whether a supported language is currently accepted.
+// All supported languages are enabled when EnabledLanguages is empty.
+func LanguageEnabled(name string) bool {
+	if !LanguageSupported(name) {
+		return false
+	}
+	if len(config.EnabledLanguages) == 0 {
+		return true
+	}
+	for _, enabled := range config.EnabledLanguages {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportedLanguages returns the names of all registered languages in sorted order
+func SupportedLanguages() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EnabledLanguages returns the names of the languages currently accepted in sorted order
+func EnabledLanguages() []string {
+	var names []string
+	for _, name := range SupportedLanguages() {
+		if LanguageEnabled(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
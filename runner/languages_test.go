@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"context"
+	"online-compiler/models"
+	"testing"
+)
+
+// echoPrograms copy stdin to stdout line by line
+var echoPrograms = map[string]string{
+	"ruby":   "STDIN.each_line { |line| puts line }",
+	"python": "import sys\nfor line in sys.stdin:\n    print(line, end='')",
+}
+
+func TestMultiLineInput(t *testing.T) {
+	interpreters := map[string]string{"ruby": "ruby", "python": "python3"}
+	input := "first line\nsecond line\n"
+
+	for language, code := range echoPrograms {
+		t.Run(language, func(t *testing.T) {
+			useLocalSandbox(t, nil, interpreters[language])
+
+			result := executeCodeWithContext(context.Background(), models.ExecuteRequest{Language: language, Code: code, Input: input})
+			if result.Error != nil {
+				t.Fatalf("execute: %v", result.Error)
+			}
+			if result.Output != input {
+				t.Errorf("execute echoed %q, want %q", result.Output, input)
+			}
+
+			results, _, err := ExecuteBatchInDocker(context.Background(), models.BatchExecuteRequest{
+				Language:  language,
+				Code:      code,
+				TestCases: []models.TestInput{{ID: "tc_1", Input: input}},
+			})
+			if err != nil {
+				t.Fatalf("batch: %v", err)
+			}
+			if results[0].Output != input {
+				t.Errorf("batch echoed %q, want %q", results[0].Output, input)
+			}
+		})
+	}
+}
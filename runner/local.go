@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// localSandbox runs code directly on the host with os/exec.
+//
+// UNSAFE: there is no isolation of any kind. Submitted code can read and
+// write anything the server can. It exists only so contributors without
+// Docker can run the server, and NewSandbox refuses it outside development.
+type localSandbox struct{}
+
+// Prepare logs a warning; there is no image to wait for
+func (localSandbox) Prepare() error {
+	log.Printf("[WARN] SANDBOX_MODE=local: code runs on the host WITHOUT isolation. Never use this in production.")
+	imageReady.Store(true)
+	// Pinned language versions run on the host toolchain as well
+	for _, image := range config.LanguageImages {
+		pinnedReady.Store(image, true)
+	}
+	return nil
+}
+
+// Available always succeeds since only the host shell is needed
+func (localSandbox) Available() error {
+	return nil
+}
+
+// Run executes the script with the host shell. Memory limits are not
+// enforced, and the memory probe reports whatever cgroup the server runs in.
+func (localSandbox) Run(ctx context.Context, spec RunSpec) ([]byte, error) {
+	if _, err := buildEnvArgs(spec.Env); err != nil {
+		return nil, err
+	}
+
+	// Scripts address the execution directory as /code, as inside a container
+	script := strings.ReplaceAll(spec.Script, "/code", spec.Dir)
+	if spec.StackSizeMB > 0 {
+		// Best effort: the host's hard limit may not allow raising it
+		script = fmt.Sprintf("ulimit -s %d 2>/dev/null; ", spec.StackSizeMB*1024) + script
+	}
+	if spec.OpenFiles > 0 {
+		script = fmt.Sprintf("ulimit -n %d 2>/dev/null; ", spec.OpenFiles) + script
+	}
+	// Stop the run as soon as it prints more than the output limit
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	output := newCappedOutput(config.MaxOutputBytes, stop)
+	cmd := exec.CommandContext(runCtx, "sh", "-c", script)
+	cmd.Stdout, cmd.Stderr = output, output
+	// Kill the program along with the shell, as stopping a container would
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.Dir = spec.Dir
+	cmd.Env = os.Environ()
+	for key, value := range spec.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	// Don't wait forever on children that outlive a killed shell
+	cmd.WaitDelay = time.Duration(spec.StopTimeout) * time.Second
+
+	err := cmd.Run()
+	if output.Exceeded() {
+		return output.Bytes(), ErrOutputLimitExceeded
+	}
+	if ctx.Err() != nil {
+		return output.Bytes(), ctx.Err()
+	}
+	return output.Bytes(), err
+}
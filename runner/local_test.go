@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"context"
+	"online-compiler/models"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useConfig runs the test under a copy of the configuration changed by edit
+func useConfig(t *testing.T, edit func(c *models.Config)) {
+	c := *config
+	if edit != nil {
+		edit(&c)
+	}
+	previous := config
+	Configure(&c)
+	t.Cleanup(func() { Configure(previous) })
+}
+
+// chdirTemp runs the test from a temporary directory, where executions create
+// their sandbox directories
+func chdirTemp(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// useLocalSandbox runs the test's executions on the host with the local
+// sandbox, under a copy of the configuration changed by edit. The test is
+// skipped when a command it needs, such as an interpreter, is missing.
+func useLocalSandbox(t *testing.T, edit func(c *models.Config), commands ...string) {
+	for _, command := range commands {
+		if _, err := exec.LookPath(command); err != nil {
+			t.Skipf("%s is not installed", command)
+		}
+	}
+	useConfig(t, func(c *models.Config) {
+		c.SandboxMode = "local"
+		c.Environment = "development"
+		if edit != nil {
+			edit(c)
+		}
+	})
+	chdirTemp(t)
+
+	previous := sandbox
+	SetSandbox(localSandbox{})
+	t.Cleanup(func() { SetSandbox(previous) })
+	if err := sandbox.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalRunStopsAtOutputLimit(t *testing.T) {
+	useLocalSandbox(t, func(c *models.Config) { c.MaxOutputBytes = 4096 }, "yes")
+
+	start := time.Now()
+	output, err := localSandbox{}.Run(context.Background(), RunSpec{Dir: t.TempDir(), Script: "yes", StopTimeout: 10})
+	if err != ErrOutputLimitExceeded {
+		t.Fatalf("runaway output returned %v, want ErrOutputLimitExceeded", err)
+	}
+	if len(output) != 4096 {
+		t.Errorf("kept %d bytes of output, want the 4096 byte limit", len(output))
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run took %v to stop after exceeding the limit", elapsed)
+	}
+}
+
+func TestLocalRunUnderOutputLimit(t *testing.T) {
+	useLocalSandbox(t, func(c *models.Config) { c.MaxOutputBytes = 4096 })
+
+	output, err := localSandbox{}.Run(context.Background(), RunSpec{Dir: t.TempDir(), Script: "printf 'hello\\n'; printf 'warn\\n' >&2"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if string(output) != "hello\nwarn\n" {
+		t.Errorf("output = %q, want it untouched", output)
+	}
+}
+
+func TestExecuteOutputLimit(t *testing.T) {
+	useLocalSandbox(t, func(c *models.Config) { c.MaxOutputBytes = 10000 }, "python3")
+
+	result := executeCodeWithContext(context.Background(), models.ExecuteRequest{
+		Language: "python",
+		Code:     "while True:\n    print('x' * 100)",
+	})
+	if result.Error != ErrOutputLimitExceeded {
+		t.Fatalf("runaway program returned %v, want ErrOutputLimitExceeded", result.Error)
+	}
+	if !strings.HasSuffix(result.Output, outputTruncatedNotice()) {
+		t.Errorf("output does not end with the truncation notice: %q", result.Output[len(result.Output)-100:])
+	}
+	if kept := len(result.Output) - len(outputTruncatedNotice()); kept != 10000 {
+		t.Errorf("kept %d bytes of output, want 10000", kept)
+	}
+
+	result = executeCodeWithContext(context.Background(), models.ExecuteRequest{
+		Language: "python",
+		Code:     "print('x' * 9000)",
+	})
+	if result.Error != nil {
+		t.Fatalf("program under the limit failed: %v", result.Error)
+	}
+	if result.Output != strings.Repeat("x", 9000)+"\n" {
+		t.Errorf("output under the limit was changed: %d bytes", len(result.Output))
+	}
+}
+
+func TestBatchOutputLimitVerdict(t *testing.T) {
+	useLocalSandbox(t, func(c *models.Config) { c.MaxCaseOutputSize = 1000 }, "python3")
+
+	results, _, err := ExecuteBatchInDocker(context.Background(), models.BatchExecuteRequest{
+		Language: "python",
+		Code:     "n = int(input())\nwhile n:\n    print('x' * 100)\nprint('ok')",
+		TestCases: []models.TestInput{
+			{ID: "tc_1", Input: "1"},
+			{ID: "tc_2", Input: "0"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteBatchInDocker: %v", err)
+	}
+
+	runaway := results[0]
+	if runaway.Verdict != models.VerdictOutputLimitExceeded || !runaway.Truncated || len(runaway.Output) != 1000 {
+		t.Errorf("runaway case: verdict %q, truncated %v, %d bytes of output; want OLE cut at 1000 bytes",
+			runaway.Verdict, runaway.Truncated, len(runaway.Output))
+	}
+	normal := results[1]
+	if normal.Verdict != "" || normal.Truncated || normal.Output != "ok\n" {
+		t.Errorf("normal case: verdict %q, truncated %v, output %q; want its output untouched", normal.Verdict, normal.Truncated, normal.Output)
+	}
+}
@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// memoryProbe is appended to in-container scripts to record the container's
+// peak and current memory usage from its own cgroup (v2 first, then v1).
+// Reading the cgroup catches the peak of short programs that a single
+// `docker stats` sample would miss.
+const memoryProbe = "(cat /sys/fs/cgroup/memory.peak || cat /sys/fs/cgroup/memory/memory.max_usage_in_bytes) > /code/memory_peak 2>/dev/null; " +
+	"(cat /sys/fs/cgroup/memory.current || cat /sys/fs/cgroup/memory/memory.usage_in_bytes) > /code/memory_current 2>/dev/null"
+
+// withMemoryProbe wraps a shell command so memory usage is recorded after it
+// runs while preserving its exit status
+func withMemoryProbe(command string) string {
+	return command + "; status=$?; " + memoryProbe + "; exit $status"
+}
+
+// readMemoryStats reads the memory usage recorded by memoryProbe in execDir
+func readMemoryStats(execDir string) ContainerStats {
+	stats := ContainerStats{
+		MemoryPeakBytes:    readBytesFile(filepath.Join(execDir, "memory_peak")),
+		MemoryCurrentBytes: readBytesFile(filepath.Join(execDir, "memory_current")),
+	}
+	stats.MemoryUsed = stats.MemoryPeakBytes / 1024
+	return stats
+}
+
+// readBytesFile parses a byte count written to path, returning 0 if
+// unavailable. The probe writes into /code, so a symlink or other
+// non-regular file left there by the program is ignored.
+func readBytesFile(path string) int64 {
+	data, err := readSandboxFile(path, maxNumberFileSize)
+	if err != nil {
+		return 0
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
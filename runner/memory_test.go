@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"online-compiler/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestReadMemoryStats(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "memory_peak"), []byte("2097152\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "memory_current"), []byte("1048576\n"), 0644)
+
+	stats := readMemoryStats(dir)
+	if stats.MemoryPeakBytes != 2097152 || stats.MemoryCurrentBytes != 1048576 || stats.MemoryUsed != 2048 {
+		t.Errorf("readMemoryStats = %+v", stats)
+	}
+}
+
+func TestReadMemoryStatsRejectsNonRegularFiles(t *testing.T) {
+	dir := t.TempDir()
+	host := filepath.Join(t.TempDir(), "host_file")
+	os.WriteFile(host, []byte("123456"), 0600)
+
+	// The program replaced the probe files before the probe could write them
+	if err := os.Symlink(host, filepath.Join(dir, "memory_peak")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(dir, "memory_current"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := readMemoryStats(dir)
+	if stats.MemoryPeakBytes != 0 || stats.MemoryCurrentBytes != 0 {
+		t.Errorf("readMemoryStats read through non-regular files: %+v", stats)
+	}
+}
+
+func TestReadBytesFileCapsRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory_peak")
+	huge := make([]byte, 1<<20)
+	for i := range huge {
+		huge[i] = '9'
+	}
+	os.WriteFile(path, huge, 0644)
+
+	if got := readBytesFile(path); got != 0 {
+		t.Errorf("readBytesFile of an oversized file = %d, want 0", got)
+	}
+}
+
+// probeSandbox stands in for a container whose cgroup reports a 3 MiB peak:
+// when the script carries memoryProbe, it writes what the probe would
+// record and then returns err
+type probeSandbox struct {
+	err     error
+	scripts []string
+}
+
+func (s *probeSandbox) Prepare() error   { return nil }
+func (s *probeSandbox) Available() error { return nil }
+
+func (s *probeSandbox) Run(ctx context.Context, spec RunSpec) ([]byte, error) {
+	s.scripts = append(s.scripts, spec.Script)
+	if strings.Contains(spec.Script, memoryProbe) {
+		os.WriteFile(filepath.Join(spec.Dir, "memory_peak"), []byte("3145728\n"), 0644)
+		os.WriteFile(filepath.Join(spec.Dir, "memory_current"), []byte("1048576\n"), 0644)
+	}
+	return []byte("ok\n"), s.err
+}
+
+// useProbeSandbox runs the test's executions on a probeSandbox failing with err
+func useProbeSandbox(t *testing.T, err error) *probeSandbox {
+	useFakeSandbox(t, func(c *models.Config) { c.CollectContainerStats = true })
+	probe := &probeSandbox{err: err}
+	previous := sandbox
+	SetSandbox(probe)
+	t.Cleanup(func() { SetSandbox(previous) })
+	return probe
+}
+
+func TestExecuteReportsProbedMemory(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"successful run", nil},
+		{"failed run", errors.New("exit status 1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := useProbeSandbox(t, tt.err)
+
+			result := executeCodeWithContext(context.Background(), models.ExecuteRequest{Language: "python", Code: "print(1)"})
+			if (result.Error != nil) != (tt.err != nil) {
+				t.Fatalf("execution error = %v, want error: %v", result.Error, tt.err != nil)
+			}
+			// The probe runs after the program and keeps its exit status
+			if len(probe.scripts) != 1 || !strings.HasSuffix(probe.scripts[0], memoryProbe+"; exit $status") {
+				t.Errorf("scripts = %q, want the program followed by the memory probe", probe.scripts)
+			}
+			if stats := result.Stats; stats.MemoryPeakBytes != 3145728 || stats.MemoryCurrentBytes != 1048576 || stats.MemoryUsed != 3072 {
+				t.Errorf("stats = %+v, want the probed 3 MiB peak", stats)
+			}
+		})
+	}
+}
+
+func TestBatchReportsProbedMemory(t *testing.T) {
+	useProbeSandbox(t, nil)
+
+	results, metrics, err := ExecuteBatchInDocker(context.Background(), models.BatchExecuteRequest{
+		Language:  "python",
+		Code:      "print(input())",
+		TestCases: []models.TestInput{{ID: "tc_0", Input: "1"}, {ID: "tc_1", Input: "2"}},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteBatchInDocker: %v", err)
+	}
+	if metrics.Memory.MemoryPeakBytes != 3145728 || metrics.Memory.MemoryUsed != 3072 {
+		t.Errorf("batch memory = %+v, want the probed 3 MiB peak", metrics.Memory)
+	}
+	// Every case shares the container, so each reports its peak
+	for _, result := range results {
+		if result.MemKb != 3072 {
+			t.Errorf("case %s memory = %d KB, want 3072", result.ID, result.MemKb)
+		}
+	}
+}
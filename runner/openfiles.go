@@ -0,0 +1,19 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tooManyOpenFiles is how EMFILE is reported by libc, Python, the JVM and most runtimes
+const tooManyOpenFiles = "Too many open files"
+
+// openFilesMessage describes a run that failed by exhausting its file descriptors
+func openFilesMessage() string {
+	return fmt.Sprintf("Too many open files (limit %d per execution)", config.MaxOpenFiles)
+}
+
+// exhaustedFiles reports whether a failed run's stderr shows it hit the open file limit
+func exhaustedFiles(stderr string) bool {
+	return config.MaxOpenFiles > 0 && strings.Contains(stderr, tooManyOpenFiles)
+}
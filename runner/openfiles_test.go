@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"online-compiler/models"
+	"testing"
+)
+
+func TestExhaustedFiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxOpenFiles int
+		stderr       string
+		want         bool
+	}{
+		{"python", 64, "OSError: [Errno 24] Too many open files: 'out.txt'", true},
+		{"c", 64, "fopen: Too many open files\n", true},
+		{"java", 64, "java.io.FileNotFoundException: f (Too many open files)", true},
+		{"other error", 64, "Segmentation fault", false},
+		{"empty stderr", 64, "", false},
+		{"different case", 64, "too many open files", false},
+		{"limit disabled", 0, "OSError: [Errno 24] Too many open files", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, func(c *models.Config) { c.MaxOpenFiles = tt.maxOpenFiles })
+			if got := exhaustedFiles(tt.stderr); got != tt.want {
+				t.Errorf("exhaustedFiles(%q) with limit %d = %v, want %v", tt.stderr, tt.maxOpenFiles, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenFilesMessage(t *testing.T) {
+	useConfig(t, func(c *models.Config) { c.MaxOpenFiles = 64 })
+	if got, want := openFilesMessage(), "Too many open files (limit 64 per execution)"; got != want {
+		t.Errorf("openFilesMessage() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrOutputLimitExceeded is returned when a run printed more than MaxOutputBytes and was stopped
+var ErrOutputLimitExceeded = errors.New("output limit exceeded")
+
+// outputTruncatedNotice is appended to the output of a run stopped by the output limit
+func outputTruncatedNotice() string {
+	return fmt.Sprintf("\n[Output truncated: the program printed more than %d bytes and was stopped]\n", config.MaxOutputBytes)
+}
+
+// cappedOutput collects a run's combined output up to limit bytes. The first
+// write past the limit calls exceeded, which stops the run; later output is
+// discarded so the program never blocks on a full pipe while being stopped.
+type cappedOutput struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	limit    int
+	over     bool
+	exceeded func()
+}
+
+// newCappedOutput returns a writer keeping at most limit bytes; a limit of 0 keeps everything
+func newCappedOutput(limit int, exceeded func()) *cappedOutput {
+	return &cappedOutput{limit: limit, exceeded: exceeded}
+}
+
+// Write implements io.Writer
+func (o *cappedOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.over {
+		return len(p), nil
+	}
+	if room := o.limit - o.buf.Len(); o.limit > 0 && len(p) > room {
+		o.buf.Write(p[:room])
+		o.over = true
+		o.exceeded()
+		return len(p), nil
+	}
+	o.buf.Write(p)
+	return len(p), nil
+}
+
+// Bytes returns a copy of the output collected so far
+func (o *cappedOutput) Bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]byte(nil), o.buf.Bytes()...)
+}
+
+// Exceeded reports whether the run printed more than the limit
+func (o *cappedOutput) Exceeded() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.over
+}
@@ -0,0 +1,38 @@
+package runner
+
+import "testing"
+
+func TestCappedOutput(t *testing.T) {
+	calls := 0
+	output := newCappedOutput(10, func() { calls++ })
+
+	output.Write([]byte("hello"))
+	if output.Exceeded() || calls != 0 {
+		t.Fatalf("output under the limit was reported as exceeded")
+	}
+	output.Write([]byte("world"))
+	if output.Exceeded() || string(output.Bytes()) != "helloworld" {
+		t.Fatalf("output at the limit = %q, exceeded %v", output.Bytes(), output.Exceeded())
+	}
+
+	// Writes past the limit are accepted and dropped so the program never blocks
+	if n, err := output.Write([]byte("!!")); n != 2 || err != nil {
+		t.Errorf("Write past the limit = %d, %v", n, err)
+	}
+	output.Write([]byte("more"))
+	if !output.Exceeded() || calls != 1 {
+		t.Errorf("exceeded %v with %d callbacks, want one callback", output.Exceeded(), calls)
+	}
+	if string(output.Bytes()) != "helloworld" {
+		t.Errorf("kept %q, want the first 10 bytes", output.Bytes())
+	}
+}
+
+func TestCappedOutputUnlimited(t *testing.T) {
+	output := newCappedOutput(0, func() { t.Errorf("an unlimited output called exceeded") })
+	big := make([]byte, 1<<20)
+	output.Write(big)
+	if len(output.Bytes()) != len(big) || output.Exceeded() {
+		t.Errorf("unlimited output kept %d of %d bytes", len(output.Bytes()), len(big))
+	}
+}
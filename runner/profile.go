@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+// profileFile is where `time -v` writes its report inside the execution directory
+const profileFile = "/code/profile.txt"
+
+// Profile holds the detailed resource usage reported by `time -v`
+type Profile struct {
+	MaxRSSKB                   int64   `json:"max_rss_kb"`
+	UserTimeSec                float64 `json:"user_time_sec"`
+	SystemTimeSec              float64 `json:"system_time_sec"`
+	MajorPageFaults            int64   `json:"major_page_faults"`
+	MinorPageFaults            int64   `json:"minor_page_faults"`
+	VoluntaryContextSwitches   int64   `json:"voluntary_context_switches"`
+	InvoluntaryContextSwitches int64   `json:"involuntary_context_switches"`
+}
+
+// withProfiler runs command under /usr/bin/time -v when the image has it,
+// and runs it unchanged otherwise
+func withProfiler(command string) string {
+	return "if [ -x /usr/bin/time ]; then /usr/bin/time -v -o " + profileFile + " " + command +
+		"; else " + command + "; fi"
+}
+
+// readProfile parses the `time -v` report at path. It returns nil when the
+// report is missing or contains none of the expected fields.
+func readProfile(path string) *Profile {
+	file, err := openSandboxFile(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var profile Profile
+	found := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Lines look like "\tMaximum resident set size (kbytes): 9876"; some
+		// labels contain colons themselves, so split on the last one
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.LastIndex(line, ": ")
+		if i < 0 {
+			continue
+		}
+		label, value := line[:i], strings.TrimSpace(line[i+2:])
+
+		var ok bool
+		switch label {
+		case "Maximum resident set size (kbytes)":
+			profile.MaxRSSKB, ok = parseProfileInt(value)
+		case "User time (seconds)":
+			profile.UserTimeSec, ok = parseProfileFloat(value)
+		case "System time (seconds)":
+			profile.SystemTimeSec, ok = parseProfileFloat(value)
+		case "Major (requiring I/O) page faults":
+			profile.MajorPageFaults, ok = parseProfileInt(value)
+		case "Minor (reclaiming a frame) page faults":
+			profile.MinorPageFaults, ok = parseProfileInt(value)
+		case "Voluntary context switches":
+			profile.VoluntaryContextSwitches, ok = parseProfileInt(value)
+		case "Involuntary context switches":
+			profile.InvoluntaryContextSwitches, ok = parseProfileInt(value)
+		}
+		found = found || ok
+	}
+	if !found {
+		return nil
+	}
+	return &profile
+}
+
+// parseProfileInt parses an integer field, reporting whether it was valid
+func parseProfileInt(value string) (int64, bool) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	return n, err == nil
+}
+
+// parseProfileFloat parses a fractional field, reporting whether it was valid
+func parseProfileFloat(value string) (float64, bool) {
+	f, err := strconv.ParseFloat(value, 64)
+	return f, err == nil
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the execution duration histogram
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// durationHistogram counts execution durations per bucket, not cumulatively
+type durationHistogram struct {
+	buckets []uint64 // One per entry of durationBuckets, plus one for +Inf
+	sum     float64
+	count   uint64
+}
+
+// executionMetrics holds the counters exported in the Prometheus format,
+// fed by the stats collector. They live here rather than in middleware, which
+// only wraps requests and does not depend on the runner.
+var executionMetrics = struct {
+	sync.Mutex
+	succeeded map[string]uint64 // by language
+	failed    map[string]uint64
+	durations map[string]*durationHistogram
+}{
+	succeeded: make(map[string]uint64),
+	failed:    make(map[string]uint64),
+	durations: make(map[string]*durationHistogram),
+}
+
+// recordExecution adds a completed execution to the exported metrics
+func recordExecution(stats ExecutionStats) {
+	seconds := stats.EndTime.Sub(stats.StartTime).Seconds()
+
+	executionMetrics.Lock()
+	defer executionMetrics.Unlock()
+
+	if stats.Success {
+		executionMetrics.succeeded[stats.Language]++
+	} else {
+		executionMetrics.failed[stats.Language]++
+	}
+
+	h, ok := executionMetrics.durations[stats.Language]
+	if !ok {
+		h = &durationHistogram{buckets: make([]uint64, len(durationBuckets)+1)}
+		executionMetrics.durations[stats.Language] = h
+	}
+	i := sort.SearchFloat64s(durationBuckets, seconds)
+	h.buckets[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// WritePrometheusMetrics writes execution counters, the execution duration
+// histogram and worker pool gauges in the Prometheus text exposition format
+func WritePrometheusMetrics(w io.Writer) {
+	executionMetrics.Lock()
+	names := make([]string, 0, len(executionMetrics.durations))
+	for language := range executionMetrics.durations {
+		names = append(names, language)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP compiler_executions_total Executions completed, by language and result.")
+	fmt.Fprintln(w, "# TYPE compiler_executions_total counter")
+	for _, language := range names {
+		label := escapeLabel(language)
+		fmt.Fprintf(w, "compiler_executions_total{language=\"%s\",result=\"success\"} %d\n", label, executionMetrics.succeeded[language])
+		fmt.Fprintf(w, "compiler_executions_total{language=\"%s\",result=\"failure\"} %d\n", label, executionMetrics.failed[language])
+	}
+
+	fmt.Fprintln(w, "# HELP compiler_execution_duration_seconds Time from starting an execution to its result.")
+	fmt.Fprintln(w, "# TYPE compiler_execution_duration_seconds histogram")
+	for _, language := range names {
+		label := escapeLabel(language)
+		h := executionMetrics.durations[language]
+		var cumulative uint64
+		for i, bound := range durationBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(w, "compiler_execution_duration_seconds_bucket{language=\"%s\",le=\"%g\"} %d\n", label, bound, cumulative)
+		}
+		fmt.Fprintf(w, "compiler_execution_duration_seconds_bucket{language=\"%s\",le=\"+Inf\"} %d\n", label, h.count)
+		fmt.Fprintf(w, "compiler_execution_duration_seconds_sum{language=\"%s\"} %g\n", label, h.sum)
+		fmt.Fprintf(w, "compiler_execution_duration_seconds_count{language=\"%s\"} %d\n", label, h.count)
+	}
+	executionMetrics.Unlock()
+
+	queue := GetQueueMetrics()
+	fmt.Fprintln(w, "# HELP compiler_queue_depth Executions waiting for a worker.")
+	fmt.Fprintln(w, "# TYPE compiler_queue_depth gauge")
+	fmt.Fprintf(w, "compiler_queue_depth %d\n", queue.QueueDepth)
+	fmt.Fprintln(w, "# HELP compiler_queue_capacity Executions that can wait for a worker.")
+	fmt.Fprintln(w, "# TYPE compiler_queue_capacity gauge")
+	fmt.Fprintf(w, "compiler_queue_capacity %d\n", queue.QueueCapacity)
+	fmt.Fprintln(w, "# HELP compiler_busy_workers Workers currently running an execution.")
+	fmt.Fprintln(w, "# TYPE compiler_busy_workers gauge")
+	fmt.Fprintf(w, "compiler_busy_workers %d\n", queue.BusyWorkers)
+	fmt.Fprintln(w, "# HELP compiler_workers Workers in the pool.")
+	fmt.Fprintln(w, "# TYPE compiler_workers gauge")
+	fmt.Fprintf(w, "compiler_workers %d\n", queue.TotalWorkers)
+}
+
+// labelEscaper escapes a Prometheus label value
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel returns value escaped for use inside a quoted label
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleLine matches a sample in the Prometheus text exposition format
+var sampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{[^}]*\})? (\S+)$`)
+
+// scrapeMetrics writes the metrics and returns each sample's value keyed by
+// name and labels, failing the test on any line outside the format
+func scrapeMetrics(t *testing.T) (map[string]float64, string) {
+	t.Helper()
+	var sb strings.Builder
+	WritePrometheusMetrics(&sb)
+
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(strings.NewReader(sb.String()))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+			continue
+		}
+		m := sampleLine.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("line is not in the exposition format: %q", line)
+		}
+		value, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			t.Fatalf("sample %q has an invalid value: %v", line, err)
+		}
+		samples[m[1]+m[2]] = value
+	}
+	return samples, sb.String()
+}
+
+// recordRun records an execution of language lasting d
+func recordRun(language string, success bool, d time.Duration) {
+	start := time.Now()
+	recordExecution(ExecutionStats{Language: language, Success: success, StartTime: start, EndTime: start.Add(d)})
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	// Counters are never reset, so each run records under a label of its own
+	language := fmt.Sprintf("prom_test_%d", time.Now().UnixNano())
+	recordRun(language, true, 30*time.Millisecond)
+	recordRun(language, true, 700*time.Millisecond)
+	recordRun(language, false, 3*time.Second)
+	recordRun(language, true, time.Minute)
+
+	samples, text := scrapeMetrics(t)
+
+	for _, name := range []string{"compiler_executions_total", "compiler_execution_duration_seconds",
+		"compiler_queue_depth", "compiler_queue_capacity", "compiler_busy_workers", "compiler_workers"} {
+		if !strings.Contains(text, "# TYPE "+name+" ") {
+			t.Errorf("no TYPE line for %s", name)
+		}
+	}
+	if !strings.Contains(text, "# TYPE compiler_execution_duration_seconds histogram") {
+		t.Errorf("compiler_execution_duration_seconds is not typed as a histogram")
+	}
+
+	if got := samples[`compiler_executions_total{language="`+language+`",result="success"}`]; got != 3 {
+		t.Errorf("successful executions = %v, want 3", got)
+	}
+	if got := samples[`compiler_executions_total{language="`+language+`",result="failure"}`]; got != 1 {
+		t.Errorf("failed executions = %v, want 1", got)
+	}
+
+	// Buckets are cumulative
+	wantBuckets := map[string]float64{
+		"0.05": 1, "0.1": 1, "0.25": 1, "0.5": 1, "1": 2, "2.5": 2, "5": 3, "10": 3, "30": 3, "+Inf": 4,
+	}
+	previous := 0.0
+	for _, le := range []string{"0.05", "0.1", "0.25", "0.5", "1", "2.5", "5", "10", "30", "+Inf"} {
+		key := `compiler_execution_duration_seconds_bucket{language="` + language + `",le="` + le + `"}`
+		got, ok := samples[key]
+		if !ok {
+			t.Errorf("missing bucket %s", key)
+			continue
+		}
+		if got != wantBuckets[le] {
+			t.Errorf("bucket le=%s = %v, want %v", le, got, wantBuckets[le])
+		}
+		if got < previous {
+			t.Errorf("bucket le=%s = %v is below the previous bucket %v", le, got, previous)
+		}
+		previous = got
+	}
+	if got := samples[`compiler_execution_duration_seconds_count{language="`+language+`"}`]; got != 4 {
+		t.Errorf("duration count = %v, want 4", got)
+	}
+	if got := samples[`compiler_execution_duration_seconds_sum{language="`+language+`"}`]; got < 63.7 || got > 63.8 {
+		t.Errorf("duration sum = %v, want 63.73", got)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escapeLabel = %q", got)
+	}
+}
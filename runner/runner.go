@@ -0,0 +1,27 @@
+package runner
+
+import (
+	"context"
+	"online-compiler/models"
+)
+
+// Executor runs code on behalf of the HTTP handlers. Handlers depend on this
+// interface rather than on the package-level functions so they can be driven
+// by a fake in tests or by other backends.
+type Executor interface {
+	Execute(ctx context.Context, req models.ExecuteRequest) (ExecutionResult, error)
+	ExecuteBatch(ctx context.Context, req models.BatchExecuteRequest) ([]BatchResult, BatchMetrics, error)
+}
+
+// DockerExecutor runs executions through the worker pool and the configured sandbox
+type DockerExecutor struct{}
+
+// Execute runs a single execution
+func (DockerExecutor) Execute(ctx context.Context, req models.ExecuteRequest) (ExecutionResult, error) {
+	return ExecuteInDocker(ctx, req)
+}
+
+// ExecuteBatch runs code against every test case in a single sandbox
+func (DockerExecutor) ExecuteBatch(ctx context.Context, req models.BatchExecuteRequest) ([]BatchResult, BatchMetrics, error) {
+	return ExecuteBatchInDocker(ctx, req)
+}
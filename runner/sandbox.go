@@ -6,19 +6,17 @@ import (
 	"path/filepath"
 )
 
+// GetLanguageSpec returns the source file name and the full compile-and-run
+// command for lang, derived from the language registry
 func GetLanguageSpec(lang, container, code string) (filename, cmd string) {
-	switch lang {
-	case "python":
-		return "main.py", "python3 /code/main.py"
-	case "go":
-		return "main.go", "go run /code/main.go"
-	case "c":
-		return "main.c", "gcc /code/main.c -o /code/a.out && /code/a.out"
-	case "cpp":
-		return "main.cpp", "g++ /code/main.cpp -o /code/a.out && /code/a.out"
-	default:
+	spec, ok := languages[lang]
+	if !ok {
 		return "main.txt", "echo 'Unsupported Language'"
 	}
+	if spec.Compile != "" {
+		return spec.FileName, spec.Compile + " && " + spec.Run
+	}
+	return spec.FileName, spec.Run
 }
 
 func WriteCodeToFile(filename, content string) error {
@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// maxNumberFileSize bounds the read of files holding a single number, such as exit codes and timings
+const maxNumberFileSize = 64
+
+// errNotRegularFile is returned for a sandbox file that is a directory,
+// device, FIFO or anything else the host must not read
+var errNotRegularFile = errors.New("not a regular file")
+
+// openSandboxFile opens a file the sandboxed program could have written. The
+// program can replace anything under /code, so a symlink is never followed,
+// where it could point at a host file, and only regular files are opened.
+// O_NONBLOCK keeps a FIFO from blocking the open.
+func openSandboxFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, errNotRegularFile)
+	}
+	return f, nil
+}
+
+// readSandboxFile reads at most limit bytes from the start of a file the
+// sandboxed program could have written
+func readSandboxFile(path string, limit int64) ([]byte, error) {
+	f, err := openSandboxFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, limit))
+}
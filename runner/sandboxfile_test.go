@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestReadSandboxFile(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("host secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	regular := filepath.Join(dir, "run_stderr.txt")
+	os.WriteFile(regular, []byte("0123456789"), 0644)
+	link := filepath.Join(dir, "compile_output.txt")
+	if err := os.Symlink(secret, link); err != nil {
+		t.Fatal(err)
+	}
+	fifo := filepath.Join(dir, "fifo")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Fatal(err)
+	}
+	subdir := filepath.Join(dir, "subdir")
+	os.Mkdir(subdir, 0755)
+
+	t.Run("regular file", func(t *testing.T) {
+		data, err := readSandboxFile(regular, 100)
+		if err != nil || string(data) != "0123456789" {
+			t.Errorf("readSandboxFile = %q, %v", data, err)
+		}
+	})
+	t.Run("read is capped", func(t *testing.T) {
+		data, err := readSandboxFile(regular, 4)
+		if err != nil || string(data) != "0123" {
+			t.Errorf("readSandboxFile with limit 4 = %q, %v", data, err)
+		}
+	})
+	t.Run("symlink is not followed", func(t *testing.T) {
+		if data, err := readSandboxFile(link, 100); err == nil {
+			t.Errorf("readSandboxFile followed a symlink and returned %q", data)
+		}
+		if got := readOutputFile(link); got != "" {
+			t.Errorf("readOutputFile followed a symlink and returned %q", got)
+		}
+	})
+	t.Run("fifo is rejected without blocking", func(t *testing.T) {
+		if _, err := readSandboxFile(fifo, 100); !errors.Is(err, errNotRegularFile) {
+			t.Errorf("readSandboxFile of a FIFO returned %v, want errNotRegularFile", err)
+		}
+	})
+	t.Run("directory is rejected", func(t *testing.T) {
+		if _, err := readSandboxFile(subdir, 100); !errors.Is(err, errNotRegularFile) {
+			t.Errorf("readSandboxFile of a directory returned %v, want errNotRegularFile", err)
+		}
+	})
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := readSandboxFile(filepath.Join(dir, "missing"), 100); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("readSandboxFile of a missing file returned %v", err)
+		}
+	})
+}
+
+func TestCopyFileRejectsSymlink(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(secret, []byte("host secret"), 0600)
+	src := filepath.Join(t.TempDir(), "a.out")
+	if err := os.Symlink(secret, src); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "a.out")
+	if err := copyFile(src, dst); err == nil {
+		t.Errorf("copyFile copied through a symlink")
+	}
+	if _, err := os.Stat(dst); err == nil {
+		t.Errorf("copyFile created %s from a symlink", dst)
+	}
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ErrBannedPattern is returned when submitted code matches a configured banned pattern
+var ErrBannedPattern = errors.New("code rejected by static scan")
+
+// BannedPattern is a regular expression that submitted code must not match
+type BannedPattern struct {
+	Pattern string `json:"pattern"`
+	Reason  string `json:"reason"` // Reported to the client when the pattern matches
+
+	re *regexp.Regexp
+}
+
+// bannedPatterns holds the patterns by language; "*" applies to every language
+var bannedPatterns map[string][]BannedPattern
+
+// LoadBannedPatterns reads banned patterns from a JSON file of the form
+//
+//	{"c": [{"pattern": "\\bfork\\s*\\(", "reason": "process creation is not allowed"}], "*": [...]}
+//
+// An empty path leaves the scan disabled. The scan is a coarse heuristic to
+// reject obviously malicious code early; it is no substitute for the sandbox.
+func LoadBannedPatterns(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read banned patterns: %w", err)
+	}
+	var patterns map[string][]BannedPattern
+	if err := json.Unmarshal(data, &patterns); err != nil {
+		return fmt.Errorf("failed to parse banned patterns: %w", err)
+	}
+	for language, list := range patterns {
+		if language != "*" && !LanguageSupported(language) {
+			return fmt.Errorf("banned patterns for unsupported language %q", language)
+		}
+		for i := range list {
+			re, err := regexp.Compile(list[i].Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid banned pattern %q for %s: %w", list[i].Pattern, language, err)
+			}
+			list[i].re = re
+		}
+	}
+	bannedPatterns = patterns
+	return nil
+}
+
+// ScanCode rejects code in language that matches one of its banned patterns,
+// reporting the reason and the line of the first match
+func ScanCode(language, code string) error {
+	for _, list := range [][]BannedPattern{bannedPatterns[language], bannedPatterns["*"]} {
+		for _, p := range list {
+			loc := p.re.FindStringIndex(code)
+			if loc == nil {
+				continue
+			}
+			reason := p.Reason
+			if reason == "" {
+				reason = fmt.Sprintf("matches banned pattern %q", p.Pattern)
+			}
+			line := strings.Count(code[:loc[0]], "\n") + 1
+			return fmt.Errorf("%w: %s (line %d)", ErrBannedPattern, reason, line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"online-compiler/models"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrShebang is returned for code in a compiled language that starts with a shebang line
+	ErrShebang = errors.New("code starts with a shebang line")
+	// ErrInvalidPath is returned for a source file path that is empty or escapes /code
+	ErrInvalidPath = errors.New("invalid source file path")
+)
+
+// utf8BOM is the byte order mark some editors prepend to UTF-8 files
+const utf8BOM = "\ufeff"
+
+// sourceCode returns code as it is written to the source file, without a
+// leading UTF-8 byte order mark, which javac and other compilers reject
+func sourceCode(code string) []byte {
+	return []byte(strings.TrimPrefix(code, utf8BOM))
+}
+
+// CheckSource rejects code that cannot compile as written. A shebang line is
+// harmless for interpreted languages but a syntax error for compiled ones,
+// and is usually a copy-paste artifact, so it is reported clearly up front.
+func CheckSource(language, code string) error {
+	lang, ok := languages[language]
+	if !ok || lang.Compile == "" {
+		return nil
+	}
+	if strings.HasPrefix(strings.TrimPrefix(code, utf8BOM), "#!") {
+		return fmt.Errorf("%w, which %s does not accept; remove the first line", ErrShebang, language)
+	}
+	return nil
+}
+
+// CheckFiles validates the source files of a multi-file program. Paths are
+// relative to /code and may not leave it. The entry file (main.c, Main.java,
+// ...) comes from code when it is set, and must otherwise be one of files.
+func CheckFiles(language, code string, files []models.SourceFile) error {
+	if len(files) > config.MaxSourceFiles {
+		return fmt.Errorf("too many source files: %d (maximum %d)", len(files), config.MaxSourceFiles)
+	}
+	entry := languages[language].FileName
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		clean, err := cleanSourcePath(file.Path)
+		if err != nil {
+			return err
+		}
+		if seen[clean] {
+			return fmt.Errorf("%w: %s is given more than once", ErrInvalidPath, file.Path)
+		}
+		seen[clean] = true
+	}
+	if code != "" && seen[entry] {
+		return fmt.Errorf("%w: %s is the entry file, which is already given as code", ErrInvalidPath, entry)
+	}
+	if code == "" && !seen[entry] {
+		return fmt.Errorf("code cannot be empty unless files include the entry file %s", entry)
+	}
+	return nil
+}
+
+// cleanSourcePath returns p cleaned, rejecting absolute paths and any path
+// that resolves outside the directory it is written to
+func cleanSourcePath(p string) (string, error) {
+	if p == "" || strings.ContainsRune(p, 0) || strings.Contains(p, "\\") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, p)
+	}
+	clean := path.Clean(p)
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: %q must stay within the program directory", ErrInvalidPath, p)
+	}
+	return clean, nil
+}
+
+// SourceSize returns the total size in bytes of a program's code and source files
+func SourceSize(code string, files []models.SourceFile) int {
+	size := len(code)
+	for _, file := range files {
+		size += len(file.Content)
+	}
+	return size
+}
+
+// writeSources writes code as lang's entry file and each of files under
+// execDir, creating the subdirectories they name
+func writeSources(execDir string, lang Language, code string, files []models.SourceFile) error {
+	if code != "" || len(files) == 0 {
+		if err := os.WriteFile(filepath.Join(execDir, lang.FileName), sourceCode(code), 0644); err != nil {
+			return fmt.Errorf("failed to write code file: %w", err)
+		}
+	}
+	for _, file := range files {
+		clean, err := cleanSourcePath(file.Path)
+		if err != nil {
+			return err
+		}
+		filePath := filepath.Join(execDir, filepath.FromSlash(clean))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0777); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", clean, err)
+		}
+		if err := os.WriteFile(filePath, sourceCode(file.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", clean, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"online-compiler/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanSourcePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string // Empty when the path must be rejected
+	}{
+		// Accepted
+		{"main.c", "main.c"},
+		{"util/helpers.c", "util/helpers.c"},
+		{"src/pkg/deep/file.go", "src/pkg/deep/file.go"},
+		{"./lib.py", "lib.py"},
+		{"a/./b.py", "a/b.py"},
+		{"a//b.py", "a/b.py"},
+		{"a/b/../c.py", "a/c.py"},
+		{"..hidden/x.py", "..hidden/x.py"},
+		{"file..txt", "file..txt"},
+
+		// Parent directories
+		{"..", ""},
+		{"../main.c", ""},
+		{"../../etc/passwd", ""},
+		{"a/../../main.c", ""},
+		{"a/b/../../../main.c", ""},
+
+		// Absolute paths
+		{"/etc/passwd", ""},
+		{"/code/main.c", ""},
+		{"//main.c", ""},
+
+		// NUL bytes
+		{"main.c\x00.txt", ""},
+		{"\x00", ""},
+
+		// Backslashes
+		{`..\main.c`, ""},
+		{`util\helpers.c`, ""},
+		{`C:\main.c`, ""},
+
+		// Nothing to write
+		{"", ""},
+		{".", ""},
+		{"a/..", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := cleanSourcePath(tt.path)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("cleanSourcePath(%q) = %q, %v; want ErrInvalidPath", tt.path, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("cleanSourcePath(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	file := func(path string) models.SourceFile { return models.SourceFile{Path: path, Content: "x"} }
+	tests := []struct {
+		name    string
+		code    string
+		files   []models.SourceFile
+		wantErr bool
+	}{
+		{"code with helpers", "int main() {}", []models.SourceFile{file("util.c"), file("lib/math.c")}, false},
+		{"entry file among files", "", []models.SourceFile{file("main.c"), file("util.c")}, false},
+		{"no entry file", "", []models.SourceFile{file("util.c")}, true},
+		{"entry file twice", "int main() {}", []models.SourceFile{file("main.c")}, true},
+		{"duplicate paths", "int main() {}", []models.SourceFile{file("util.c"), file("./util.c")}, true},
+		{"escaping path", "int main() {}", []models.SourceFile{file("../util.c")}, true},
+		{"absolute path", "int main() {}", []models.SourceFile{file("/tmp/util.c")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckFiles("c", tt.code, tt.files); (err != nil) != tt.wantErr {
+				t.Errorf("CheckFiles() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteSourcesStaysInDirectory(t *testing.T) {
+	root := t.TempDir()
+	execDir := filepath.Join(root, "exec")
+	os.Mkdir(execDir, 0755)
+
+	files := []models.SourceFile{{Path: "lib/deep/util.c", Content: "int f;"}}
+	if err := writeSources(execDir, languages["c"], "int main() {}", files); err != nil {
+		t.Fatalf("writeSources: %v", err)
+	}
+	for _, name := range []string{"main.c", "lib/deep/util.c"} {
+		if _, err := os.Stat(filepath.Join(execDir, name)); err != nil {
+			t.Errorf("%s was not written: %v", name, err)
+		}
+	}
+
+	escaping := []models.SourceFile{{Path: "../outside.c", Content: "x"}}
+	if err := writeSources(execDir, languages["c"], "int main() {}", escaping); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("writeSources with an escaping path returned %v, want ErrInvalidPath", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside.c")); err == nil {
+		t.Errorf("writeSources wrote outside the execution directory")
+	}
+}
+
+func TestWriteSourcesStripsBOM(t *testing.T) {
+	execDir := t.TempDir()
+	files := []models.SourceFile{{Path: "Util.java", Content: utf8BOM + "class Util {}"}}
+	if err := writeSources(execDir, languages["java"], utf8BOM+"public class Main {}", files); err != nil {
+		t.Fatalf("writeSources: %v", err)
+	}
+
+	for name, want := range map[string]string{"Main.java": "public class Main {}", "Util.java": "class Util {}"} {
+		data, err := os.ReadFile(filepath.Join(execDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s holds %q, want %q without the BOM", name, data, want)
+		}
+	}
+}
+
+func TestExecuteCompilesWithoutBOM(t *testing.T) {
+	fake := useFakeSandbox(t, nil)
+	code := "public class Main { public static void main(String[] a) {} }"
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		executeCodeWithContext(context.Background(), models.ExecuteRequest{Language: "java", Code: utf8BOM + code})
+	}()
+
+	// The container compiles what it finds in the execution directory
+	spec := <-fake.started
+	data, err := os.ReadFile(filepath.Join(spec.Dir, "Main.java"))
+	close(fake.release)
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != code {
+		t.Errorf("the compiler was given %q, want the code without its BOM", data)
+	}
+}
+
+func TestCheckSourceBOM(t *testing.T) {
+	if err := CheckSource("c", utf8BOM+"int main() { return 0; }"); err != nil {
+		t.Errorf("CheckSource rejected BOM-prefixed code: %v", err)
+	}
+	if err := CheckSource("c", utf8BOM+"#!/usr/bin/tcc -run\nint main() {}"); !errors.Is(err, ErrShebang) {
+		t.Errorf("CheckSource(BOM and shebang) = %v, want ErrShebang", err)
+	}
+}
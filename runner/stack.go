@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// segfaultExitCode is the exit status of a program killed by SIGSEGV, which
+// is how a stack overflow shows up
+const segfaultExitCode = 128 + 11
+
+// StackLimit returns the stack size in MB applied to a run that requested
+// requested MB: 0 selects DEFAULT_STACK_SIZE_MB and larger requests are
+// clamped to MAX_STACK_SIZE_MB
+func StackLimit(requested int) int {
+	if requested <= 0 {
+		requested = config.DefaultStackSizeMB
+	}
+	if requested > config.MaxStackSizeMB {
+		requested = config.MaxStackSizeMB
+	}
+	return requested
+}
+
+// segfaultMessage describes a segmentation fault under a stack limit of stackMB
+func segfaultMessage(stackMB int) string {
+	return fmt.Sprintf("Segmentation fault, possibly a stack overflow (stack limit %d MB)", stackMB)
+}
+
+// describeCrash adds the likely cause to the error of a run that crashed
+// with a segmentation fault; other errors are returned unchanged
+func describeCrash(err error, stackMB int) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == segfaultExitCode {
+		return fmt.Errorf("%s: %w", segfaultMessage(stackMB), err)
+	}
+	return err
+}
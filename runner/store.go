@@ -0,0 +1,306 @@
+package runner
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the "pgx" database/sql driver
+	_ "modernc.org/sqlite"             // Registers the "sqlite" database/sql driver
+)
+
+// ErrResultNotFound is returned by a ResultStore when no result has the requested ID
+var ErrResultNotFound = errors.New("result not found")
+
+// ResultStore persists execution statistics
+type ResultStore interface {
+	Save(stats ExecutionStats) error
+	List(limit, offset int) ([]ExecutionStats, error)
+	Get(id string) (ExecutionStats, error)
+	SaveOutputHashes(hashes []OutputHash) error
+}
+
+// OutputHash is the hash of one test case's normalized output in a submission,
+// kept so identical outputs can later be found across submissions
+type OutputHash struct {
+	SubmissionID string    `json:"submission_id"`
+	CaseID       string    `json:"case_id"`
+	Language     string    `json:"language"`
+	Hash         string    `json:"hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SaveOutputHashes persists output hashes to the configured result store
+func SaveOutputHashes(hashes []OutputHash) error {
+	return currentResultStore().SaveOutputHashes(hashes)
+}
+
+// NewResultStore creates the result store selected by the configuration.
+// The sqlite backend takes a file path as dsn, the postgres backend a
+// connection URL.
+func NewResultStore(backend, dsn string, capacity int) (ResultStore, error) {
+	switch backend {
+	case "", "memory":
+		return NewMemoryStore(capacity), nil
+	case "sqlite":
+		return NewSQLStore("sqlite", dsn, false)
+	case "postgres":
+		return NewSQLStore("pgx", dsn, true)
+	default:
+		return nil, fmt.Errorf("unsupported result store: %s", backend)
+	}
+}
+
+// MemoryStore keeps the most recent results in memory
+type MemoryStore struct {
+	mu sync.RWMutex
+	// Ring buffer of results once capacity are stored; next is the oldest
+	results  []ExecutionStats
+	next     int
+	index    map[string]int // Position of each result in results
+	hashes   []OutputHash
+	capacity int
+}
+
+// NewMemoryStore creates an in-memory store holding at most capacity results
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		index:    make(map[string]int),
+		capacity: capacity,
+	}
+}
+
+// Save stores stats, evicting the oldest result when the store is full
+func (s *MemoryStore) Save(stats ExecutionStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i, ok := s.index[stats.RequestID]; ok {
+		s.results[i] = stats
+		return nil
+	}
+
+	if s.capacity <= 0 || len(s.results) < s.capacity {
+		s.index[stats.RequestID] = len(s.results)
+		s.results = append(s.results, stats)
+		return nil
+	}
+
+	// Full: overwrite the oldest result
+	delete(s.index, s.results[s.next].RequestID)
+	s.results[s.next] = stats
+	s.index[stats.RequestID] = s.next
+	s.next = (s.next + 1) % s.capacity
+	return nil
+}
+
+// List returns results newest first
+func (s *MemoryStore) List(limit, offset int) ([]ExecutionStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []ExecutionStats
+	n := len(s.results)
+	for k := offset; k < n && len(list) < limit; k++ {
+		// The newest result sits just before the oldest
+		list = append(list, s.results[((s.next-1-k)%n+n)%n])
+	}
+	return list, nil
+}
+
+// Get returns the result with the given request ID
+func (s *MemoryStore) Get(id string) (ExecutionStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.index[id]
+	if !ok {
+		return ExecutionStats{}, ErrResultNotFound
+	}
+	return s.results[i], nil
+}
+
+// SaveOutputHashes stores hashes, keeping at most capacity submissions' worth of recent hashes
+func (s *MemoryStore) SaveOutputHashes(hashes []OutputHash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hashes = append(s.hashes, hashes...)
+	if limit := s.capacity * 100; s.capacity > 0 && len(s.hashes) > limit {
+		s.hashes = append([]OutputHash(nil), s.hashes[len(s.hashes)-limit:]...)
+	}
+	return nil
+}
+
+// SQLStore persists results in a SQL database
+type SQLStore struct {
+	db *sql.DB
+	// numbered selects $1-style placeholders (Postgres) instead of ?
+	numbered bool
+}
+
+// NewSQLStore opens the database and creates the results table if needed
+func NewSQLStore(driver, dsn string, numbered bool) (*SQLStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s result store: %w", driver, err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s result store: %w", driver, err)
+	}
+
+	if driver == "sqlite" {
+		// SQLite allows one writer at a time; sharing a connection queues
+		// writes instead of failing them with "database is locked"
+		db.SetMaxOpenConns(1)
+	}
+
+	store := &SQLStore{db: db, numbered: numbered}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS execution_stats (
+		request_id    TEXT PRIMARY KEY,
+		language      TEXT NOT NULL,
+		code_size     INTEGER NOT NULL,
+		success       BOOLEAN NOT NULL,
+		error_message TEXT NOT NULL,
+		start_time    TIMESTAMP NOT NULL,
+		end_time      TIMESTAMP NOT NULL,
+		tenant_id     TEXT NOT NULL DEFAULT '',
+		user_id       TEXT NOT NULL DEFAULT ''
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create results table: %w", err)
+	}
+	// Tables created before results recorded their caller lack its columns
+	for _, column := range []string{"tenant_id", "user_id"} {
+		if _, err := db.Exec("SELECT " + column + " FROM execution_stats LIMIT 0"); err == nil {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE execution_stats ADD COLUMN " + column + " TEXT NOT NULL DEFAULT ''"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to add %s to results table: %w", column, err)
+		}
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS output_hashes (
+		submission_id TEXT NOT NULL,
+		case_id       TEXT NOT NULL,
+		language      TEXT NOT NULL,
+		hash          TEXT NOT NULL,
+		created_at    TIMESTAMP NOT NULL,
+		PRIMARY KEY (submission_id, case_id)
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create output hashes table: %w", err)
+	}
+	return store, nil
+}
+
+// Close closes the underlying database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// rebind rewrites ? placeholders into the dialect used by the database
+func (s *SQLStore) rebind(query string) string {
+	if !s.numbered {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// statsColumns are the execution_stats columns read into ExecutionStats by scanStats
+const statsColumns = "request_id, language, code_size, success, error_message, start_time, end_time, tenant_id, user_id"
+
+// scanStats reads a row of statsColumns
+func scanStats(row interface{ Scan(dest ...any) error }) (ExecutionStats, error) {
+	var stats ExecutionStats
+	err := row.Scan(&stats.RequestID, &stats.Language, &stats.CodeSize, &stats.Success,
+		&stats.ErrorMessage, &stats.StartTime, &stats.EndTime, &stats.TenantID, &stats.UserID)
+	return stats, err
+}
+
+// Save inserts or replaces stats
+func (s *SQLStore) Save(stats ExecutionStats) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO execution_stats (`+statsColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (request_id) DO UPDATE SET
+		success = excluded.success, error_message = excluded.error_message, end_time = excluded.end_time`),
+		stats.RequestID, stats.Language, stats.CodeSize, stats.Success, stats.ErrorMessage,
+		stats.StartTime, stats.EndTime, stats.TenantID, stats.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to save result: %w", err)
+	}
+	return nil
+}
+
+// List returns results newest first
+func (s *SQLStore) List(limit, offset int) ([]ExecutionStats, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT `+statsColumns+`
+		FROM execution_stats ORDER BY start_time DESC LIMIT ? OFFSET ?`), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list results: %w", err)
+	}
+	defer rows.Close()
+
+	var list []ExecutionStats
+	for rows.Next() {
+		stats, err := scanStats(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read result: %w", err)
+		}
+		list = append(list, stats)
+	}
+	return list, rows.Err()
+}
+
+// Get returns the result with the given request ID
+func (s *SQLStore) Get(id string) (ExecutionStats, error) {
+	stats, err := scanStats(s.db.QueryRow(s.rebind(`SELECT `+statsColumns+`
+		FROM execution_stats WHERE request_id = ?`), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ExecutionStats{}, ErrResultNotFound
+	}
+	if err != nil {
+		return ExecutionStats{}, fmt.Errorf("failed to get result: %w", err)
+	}
+	return stats, nil
+}
+
+// SaveOutputHashes inserts hashes in a single transaction
+func (s *SQLStore) SaveOutputHashes(hashes []OutputHash) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save output hashes: %w", err)
+	}
+	for _, h := range hashes {
+		_, err := tx.Exec(s.rebind(`INSERT INTO output_hashes
+			(submission_id, case_id, language, hash, created_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (submission_id, case_id) DO UPDATE SET hash = excluded.hash`),
+			h.SubmissionID, h.CaseID, h.Language, h.Hash, h.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to save output hash: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save output hashes: %w", err)
+	}
+	return nil
+}
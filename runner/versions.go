@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupportedVersion is returned for a language version that has no pinned image
+var ErrUnsupportedVersion = errors.New("unsupported language version")
+
+// pinnedReady holds the pinned images known to exist locally
+var pinnedReady sync.Map
+
+// ResolveImage returns the sandbox image for version of language. An empty
+// version runs on the default image; any other version must be pinned to an
+// image tag in LANGUAGE_IMAGES.
+func ResolveImage(language, version string) (string, error) {
+	if version == "" {
+		return config.Image, nil
+	}
+	if image, ok := config.LanguageImages[language+"@"+version]; ok {
+		return image, nil
+	}
+	return "", fmt.Errorf("%w: %s %s", ErrUnsupportedVersion, language, version)
+}
+
+// LanguageVersions returns the pinned versions of language and their images
+func LanguageVersions(language string) map[string]string {
+	var versions map[string]string
+	for key, image := range config.LanguageImages {
+		name, version, ok := strings.Cut(key, "@")
+		if !ok || name != language {
+			continue
+		}
+		if versions == nil {
+			versions = make(map[string]string)
+		}
+		versions[version] = image
+	}
+	return versions
+}
+
+// imageAvailable reports whether executions may run on image
+func imageAvailable(image string) bool {
+	if image == config.Image {
+		return ImageReady()
+	}
+	_, ok := pinnedReady.Load(image)
+	return ok
+}
+
+// preparePinnedImages prepares every image pinned to a language version,
+// in a stable order so startup failures are reported deterministically
+func preparePinnedImages() error {
+	images := make([]string, 0, len(config.LanguageImages))
+	for _, image := range config.LanguageImages {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	for i, image := range images {
+		if image == config.Image || (i > 0 && images[i-1] == image) {
+			continue
+		}
+		image := image
+		if err := prepareImage(image, func() { pinnedReady.Store(image, true) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// warmResetScript clears what an execution may leave behind in a warm
+// container outside /code: processes, which kill -1 signals except for
+// itself and init, and files in the writable scratch directories
+const warmResetScript = "kill -9 -1 2>/dev/null; find /tmp /var/tmp /dev/shm /sandbox -mindepth 1 -delete 2>/dev/null; true"
+
+// errWarmContainerGone is returned when a warm container died before running
+// an execution, which then falls back to a fresh container
+var errWarmContainerGone = errors.New("warm container is no longer running")
+
+// warmContainer is an idle container kept running with dir mounted at /code
+type warmContainer struct {
+	name string
+	dir  string
+}
+
+// warmPool keeps containers for one language running so executions are
+// dispatched with docker exec instead of starting a container each time
+type warmPool struct {
+	spec RunSpec // Limits every member is started with
+	idle chan *warmContainer
+}
+
+var (
+	// warmPools holds a pool per enabled language once startWarmPools has run
+	warmPools = struct {
+		sync.RWMutex
+		byLanguage map[string]*warmPool
+		closed     bool
+	}{}
+	startPools sync.Once
+)
+
+// startWarmPools starts WarmPoolSize containers per enabled language. It is
+// called once the default image is available.
+func startWarmPools() {
+	if config.WarmPoolSize <= 0 {
+		return
+	}
+	startPools.Do(func() {
+		pools := make(map[string]*warmPool)
+		for _, language := range EnabledLanguages() {
+			pool := &warmPool{
+				spec: RunSpec{
+					Language:      language,
+					MemoryLimitMB: config.MemoryLimitFor(language),
+					CPUs:          config.CPULimitFor(language),
+					PidsLimit:     config.PidsLimitFor(language),
+					StopTimeout:   1,
+					StackSizeMB:   StackLimit(0),
+					OpenFiles:     config.MaxOpenFiles,
+				},
+				idle: make(chan *warmContainer, config.WarmPoolSize),
+			}
+			pools[language] = pool
+			for i := 0; i < config.WarmPoolSize; i++ {
+				go pool.add()
+			}
+		}
+
+		warmPools.Lock()
+		warmPools.byLanguage = pools
+		warmPools.Unlock()
+		log.Printf("[INFO] Starting %d warm containers for each of %d languages", config.WarmPoolSize, len(pools))
+	})
+}
+
+// stopWarmPools removes the idle warm containers and stops replacing them.
+// It must be called once no executions are running.
+func stopWarmPools() {
+	warmPools.Lock()
+	warmPools.closed = true
+	pools := warmPools.byLanguage
+	warmPools.Unlock()
+
+	for _, pool := range pools {
+		for c := pool.acquire(); c != nil; c = pool.acquire() {
+			pool.remove(c)
+		}
+	}
+}
+
+// poolsClosed reports whether stopWarmPools has run
+func poolsClosed() bool {
+	warmPools.RLock()
+	defer warmPools.RUnlock()
+	return warmPools.closed
+}
+
+// warmPoolFor returns the pool able to run spec, or nil if spec needs a
+// fresh container: no pool exists for its language, or it asks for an image
+// or limits other than those the pool's containers were started with
+func warmPoolFor(spec RunSpec) *warmPool {
+	warmPools.RLock()
+	pool := warmPools.byLanguage[spec.Language]
+	warmPools.RUnlock()
+	if pool == nil || (spec.Image != "" && spec.Image != config.Image) {
+		return nil
+	}
+	want := pool.spec
+	if spec.MemoryLimitMB != want.MemoryLimitMB || spec.CPUs != want.CPUs || spec.PidsLimit != want.PidsLimit ||
+		spec.StackSizeMB != want.StackSizeMB || spec.OpenFiles != want.OpenFiles {
+		return nil
+	}
+	return pool
+}
+
+// acquire takes an idle container without waiting, returning nil if none is free
+func (p *warmPool) acquire() *warmContainer {
+	select {
+	case c := <-p.idle:
+		return c
+	default:
+		return nil
+	}
+}
+
+// add starts a new member and makes it available
+func (p *warmPool) add() {
+	id := newExecID()
+	dir, err := filepath.Abs(filepath.Join("sandbox", "warm_"+id))
+	if err != nil {
+		log.Printf("[WARN] Failed to create warm container directory: %v", err)
+		return
+	}
+	if err := createExecDir(dir); err != nil {
+		log.Printf("[WARN] Failed to create warm container directory: %v", err)
+		return
+	}
+
+	spec := p.spec
+	spec.Name = fmt.Sprintf("compiler_warm_%s_%s", p.spec.Language, id)
+	args := append([]string{"run", "-d"}, containerArgs(spec)...)
+	args = append(args, "-v", dir+":/code", config.Image, "sleep", "infinity")
+	if output, err := dockerCommand(args...).CombinedOutput(); err != nil {
+		log.Printf("[WARN] Failed to start warm container %s: %v: %s", spec.Name, err, strings.TrimSpace(string(output)))
+		p.remove(&warmContainer{name: spec.Name, dir: dir})
+		return
+	}
+	p.put(&warmContainer{name: spec.Name, dir: dir})
+}
+
+// put makes c available again, or removes it once the pools are stopped
+func (p *warmPool) put(c *warmContainer) {
+	if poolsClosed() {
+		p.remove(c)
+		return
+	}
+	p.idle <- c
+}
+
+// remove deletes c and its directory
+func (p *warmPool) remove(c *warmContainer) {
+	if output, err := dockerCommand("rm", "-f", c.name).CombinedOutput(); err != nil {
+		log.Printf("[WARN] Failed to remove warm container %s: %s", c.name, strings.TrimSpace(string(output)))
+	}
+	os.RemoveAll(c.dir)
+}
+
+// retire removes c in the background and starts a replacement
+func (p *warmPool) retire(c *warmContainer) {
+	go func() {
+		p.remove(c)
+		if !poolsClosed() {
+			p.add()
+		}
+	}()
+}
+
+// release resets c and returns it to the pool, replacing it if the reset fails.
+// It runs after the execution has returned, off the request path.
+func (p *warmPool) release(c *warmContainer) {
+	if output, err := dockerCommand("exec", c.name, "sh", "-c", warmResetScript).CombinedOutput(); err != nil {
+		log.Printf("[WARN] Failed to reset warm container %s, replacing it: %v: %s", c.name, err, strings.TrimSpace(string(output)))
+		p.retire(c)
+		return
+	}
+	p.put(c)
+}
+
+// run executes spec in c. The execution directory's contents are moved into
+// c's /code for the run and moved back afterwards, so callers read results
+// from spec.Dir exactly as after a fresh container. When ctx ends first, c is
+// killed and replaced.
+func (p *warmPool) run(ctx context.Context, c *warmContainer, spec RunSpec) ([]byte, error) {
+	envArgs, err := buildEnvArgs(spec.Env)
+	if err != nil {
+		p.put(c)
+		return nil, err
+	}
+	if err := moveEntries(spec.Dir, c.dir); err != nil {
+		moveEntries(c.dir, spec.Dir)
+		p.retire(c)
+		return nil, fmt.Errorf("failed to prepare warm container: %w", err)
+	}
+
+	args := append([]string{"exec"}, envArgs...)
+	args = append(args, c.name, "sh", "-c", spec.Script)
+	cmd := dockerCommand(args...)
+
+	// Stop the run as soon as it prints more than the output limit
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	output := newCappedOutput(config.MaxOutputBytes, stop)
+	cmd.Stdout, cmd.Stderr = output, output
+
+	log.Printf("[DEBUG] Running in warm container: %s", strings.Join(redactEnvArgs(cmd.Args), " "))
+
+	done := make(chan struct{})
+	var cmdErr error
+	go func() {
+		cmdErr = cmd.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-runCtx.Done():
+		// Removing the container stops the program with it
+		dockerCommand("rm", "-f", c.name).Run()
+		<-done
+		moveEntries(c.dir, spec.Dir)
+		p.retire(c)
+		if output.Exceeded() {
+			return output.Bytes(), ErrOutputLimitExceeded
+		}
+		return nil, ctx.Err()
+	}
+
+	if err := moveEntries(c.dir, spec.Dir); err != nil {
+		p.retire(c)
+		return output.Bytes(), fmt.Errorf("failed to collect results from warm container: %w", err)
+	}
+	if isContainerGone(output.Bytes(), cmdErr) {
+		p.retire(c)
+		return output.Bytes(), errWarmContainerGone
+	}
+	go p.release(c)
+	return output.Bytes(), cmdErr
+}
+
+// isContainerGone reports whether a docker exec failed because its container is not running
+func isContainerGone(output []byte, err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	return strings.Contains(string(output), "is not running") || strings.Contains(string(output), "No such container")
+}
+
+// moveEntries moves every entry of src into dst. Both directories live under
+// the sandbox directory, so each move is a rename.
+func moveEntries(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Rename(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
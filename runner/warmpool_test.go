@@ -0,0 +1,267 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"online-compiler/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDockerScript stands in for the docker CLI. It logs every invocation,
+// starts "containers" instantly and answers docker exec without running the
+// script, which for the reset script would signal every host process. Files
+// in its directory switch on failures: reset_fails and container_gone.
+const fakeDockerScript = `#!/bin/sh
+dir=$(dirname "$0")
+echo "$*" >> "$dir/calls"
+case "$1" in
+run) echo 0123456789ab ;;
+exec)
+	for script; do :; done
+	case "$script" in
+	*"kill -9 -1"*) [ -f "$dir/reset_fails" ] && exit 1; exit 0 ;;
+	esac
+	if [ -f "$dir/container_gone" ]; then
+		echo "Error response from daemon: container is not running" >&2
+		exit 1
+	fi
+	if [ -f "$dir/slow" ]; then sleep 1; fi
+	echo "ran: $script"
+	;;
+esac
+exit 0
+`
+
+// fakeDocker points the runner at fakeDockerScript and runs the test from a
+// temporary directory, so warm container directories are created there. It
+// returns the directory holding the script and its call log.
+func fakeDocker(t *testing.T) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "docker")
+	if err := os.WriteFile(path, []byte(fakeDockerScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	useConfig(t, func(c *models.Config) {
+		c.DockerPath = path
+		c.DockerHost = ""
+	})
+	chdirTemp(t)
+	return dir
+}
+
+// dockerCalls returns the logged fake docker invocations starting with command
+func dockerCalls(t *testing.T, dir, command string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	var calls []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, command+" ") {
+			calls = append(calls, line)
+		}
+	}
+	return calls
+}
+
+// newTestPool returns a pool for python holding size started containers
+func newTestPool(t *testing.T, size int) *warmPool {
+	pool := &warmPool{
+		spec: RunSpec{Language: "python", MemoryLimitMB: 256, StopTimeout: 1},
+		idle: make(chan *warmContainer, size),
+	}
+	for i := 0; i < size; i++ {
+		pool.add()
+	}
+	if len(pool.idle) != size {
+		t.Fatalf("pool started %d containers, want %d", len(pool.idle), size)
+	}
+	return pool
+}
+
+// waitIdle waits until the pool holds n idle containers. Taking them from the
+// channel and putting them back orders the test after the goroutines that
+// returned them.
+func waitIdle(t *testing.T, pool *warmPool, n int) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	taken := make([]*warmContainer, 0, n)
+	for len(taken) < n {
+		select {
+		case c := <-pool.idle:
+			taken = append(taken, c)
+		case <-timeout:
+			t.Fatalf("pool has %d idle containers, want %d", len(taken), n)
+		}
+	}
+	for _, c := range taken {
+		pool.idle <- c
+	}
+}
+
+func TestWarmPoolAcquireRelease(t *testing.T) {
+	dir := fakeDocker(t)
+	pool := newTestPool(t, 2)
+
+	starts := dockerCalls(t, dir, "run")
+	if len(starts) != 2 {
+		t.Fatalf("got %d docker run calls, want 2", len(starts))
+	}
+	for _, call := range starts {
+		if !strings.Contains(call, "run -d ") || !strings.Contains(call, "--memory=256m") || !strings.HasSuffix(call, "sleep infinity") {
+			t.Errorf("warm container started with %q", call)
+		}
+	}
+
+	first, second := pool.acquire(), pool.acquire()
+	if first == nil || second == nil || first == second {
+		t.Fatalf("acquire returned %v and %v, want two distinct containers", first, second)
+	}
+	if c := pool.acquire(); c != nil {
+		t.Fatalf("acquire from an empty pool returned %v, want nil", c)
+	}
+
+	pool.release(first)
+	if c := pool.acquire(); c != first {
+		t.Errorf("acquire after release returned %v, want the released container", c)
+	}
+	resets := dockerCalls(t, dir, "exec")
+	if len(resets) != 1 || !strings.Contains(resets[0], first.name) || !strings.Contains(resets[0], warmResetScript) {
+		t.Errorf("release ran %q, want the reset script in %s", resets, first.name)
+	}
+}
+
+func TestWarmPoolReplacesContainerFailingReset(t *testing.T) {
+	dir := fakeDocker(t)
+	pool := newTestPool(t, 1)
+	c := pool.acquire()
+
+	os.WriteFile(filepath.Join(dir, "reset_fails"), nil, 0644)
+	pool.release(c)
+	waitIdle(t, pool, 1)
+
+	replacement := pool.acquire()
+	if replacement == c {
+		t.Fatalf("a container that failed its reset was returned to the pool")
+	}
+	if removes := dockerCalls(t, dir, "rm"); len(removes) != 1 || !strings.Contains(removes[0], c.name) {
+		t.Errorf("got removals %q, want %s removed", removes, c.name)
+	}
+	if _, err := os.Stat(c.dir); !os.IsNotExist(err) {
+		t.Errorf("directory of the retired container still exists: %v", err)
+	}
+}
+
+func TestWarmPoolRun(t *testing.T) {
+	fakeDocker(t)
+	pool := newTestPool(t, 1)
+	c := pool.acquire()
+
+	execDir := t.TempDir()
+	os.WriteFile(filepath.Join(execDir, "main.py"), []byte("print(1)"), 0644)
+
+	output, err := pool.run(context.Background(), c, RunSpec{Name: "compiler_x", Dir: execDir, Script: "python3 /code/main.py"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(string(output), "ran: python3 /code/main.py") {
+		t.Errorf("run output = %q", output)
+	}
+
+	// The execution directory's contents come back after the run
+	if _, err := os.Stat(filepath.Join(execDir, "main.py")); err != nil {
+		t.Errorf("main.py was not moved back to the execution directory: %v", err)
+	}
+	if entries, _ := os.ReadDir(c.dir); len(entries) != 0 {
+		t.Errorf("warm container directory kept %d entries after the run", len(entries))
+	}
+	waitIdle(t, pool, 1)
+}
+
+func TestWarmPoolRunContainerGone(t *testing.T) {
+	dir := fakeDocker(t)
+	pool := newTestPool(t, 1)
+	c := pool.acquire()
+
+	os.WriteFile(filepath.Join(dir, "container_gone"), nil, 0644)
+	_, err := pool.run(context.Background(), c, RunSpec{Name: "compiler_x", Dir: t.TempDir(), Script: "true"})
+	if !errors.Is(err, errWarmContainerGone) {
+		t.Fatalf("run in a dead container returned %v, want errWarmContainerGone", err)
+	}
+
+	// The dead container is replaced
+	waitIdle(t, pool, 1)
+	if replacement := pool.acquire(); replacement == c {
+		t.Errorf("a dead container was returned to the pool")
+	}
+}
+
+func TestWarmRunReportsWarmContainer(t *testing.T) {
+	dir := fakeDocker(t)
+	pool := newTestPool(t, 1)
+	warmPools.Lock()
+	warmPools.byLanguage = map[string]*warmPool{"python": pool}
+	warmPools.Unlock()
+	t.Cleanup(func() {
+		warmPools.Lock()
+		warmPools.byLanguage = nil
+		warmPools.Unlock()
+	})
+	c := <-pool.idle
+	pool.idle <- c
+
+	os.WriteFile(filepath.Join(dir, "slow"), nil, 0644)
+	ctx, done := trackExecution(context.Background(), ActiveExecution{ID: "warm_test", Container: "compiler_warm_test"})
+	defer done()
+
+	spec := pool.spec
+	spec.Name, spec.Dir, spec.Script = "compiler_warm_test", t.TempDir(), "true"
+	finished := make(chan error, 1)
+	go func() {
+		_, err := dockerSandbox{}.Run(ctx, spec)
+		finished <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	container := ""
+	for container != c.name && time.Now().Before(deadline) {
+		for _, exec := range ActiveExecutions() {
+			if exec.ID == "warm_test" {
+				container = exec.Container
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if container != c.name {
+		t.Errorf("active execution reports container %q, want warm container %q", container, c.name)
+	}
+	if err := <-finished; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+	waitIdle(t, pool, 1)
+}
+
+func TestWarmPoolLogRedactsEnv(t *testing.T) {
+	dir := fakeDocker(t)
+	useConfig(t, func(c *models.Config) { c.AllowedEnvVars = []string{"API_TOKEN"} })
+	pool := newTestPool(t, 1)
+	logged := captureLog(t)
+
+	spec := RunSpec{Name: "compiler_x", Dir: t.TempDir(), Script: "true", Env: map[string]string{"API_TOKEN": "s3cret"}}
+	if _, err := pool.run(context.Background(), pool.acquire(), spec); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	waitIdle(t, pool, 1)
+
+	if calls := dockerCalls(t, dir, "exec"); len(calls) == 0 || !strings.Contains(calls[0], "-e API_TOKEN=s3cret") {
+		t.Errorf("docker exec calls = %q, want the variable passed", calls)
+	}
+	if strings.Contains(logged.String(), "s3cret") {
+		t.Errorf("the warm run was logged with the variable's value: %s", logged)
+	}
+}
@@ -0,0 +1,29 @@
+package version
+
+import "runtime"
+
+// Build information, populated at build time via:
+//
+//	go build -ldflags "-X online-compiler/version.Commit=$(git rev-parse HEAD) -X online-compiler/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info describes the running build
+type Info struct {
+	Commit      string `json:"commit"`
+	BuildTime   string `json:"build_time"`
+	GoVersion   string `json:"go_version"`
+	Image       string `json:"image,omitempty"`
+	ImageDigest string `json:"image_digest,omitempty"`
+}
+
+// Get returns the build information for the running binary
+func Get() Info {
+	return Info{
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}